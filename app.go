@@ -0,0 +1,148 @@
+package ghost
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Middleware wraps a http.Handler.
+type Middleware func(http.Handler) http.Handler
+
+// Route is a prefix which an App serves.
+type Route struct {
+	// Path is the prefix, which ends with a slash.
+	Path string `json:"path"`
+	// Resource is the type name of the resources served by Register and RegisterS, or empty for Handle.
+	Resource string `json:"resource,omitempty"`
+}
+
+// App is a http.Handler which serves several resources under prefixes.
+// The resources registered with Register and RegisterS share the ErrorHandler and the Paging of the App,
+// and all routes share the middlewares added with Use.
+// App responds to GET and HEAD / with the index of its routes, encoded with Index, to OPTIONS / with the Allow header,
+// and to unknown paths with ErrNotFound.
+type App struct {
+	// ErrorHandler handles the errors of the App and of its resources.
+	ErrorHandler func(error) http.Handler
+	// Index are the Encodings of the index of the routes, negotiated like those of resources.
+	Index Encodings[Route]
+	// Paging limits the pages of the resources registered afterwards, see WithPaging.
+	Paging Paging
+
+	mux         *http.ServeMux
+	routes      []Route
+	middlewares []Middleware
+	// handler is the mux wrapped with the middlewares
+	handler http.Handler
+}
+
+// NewApp returns an App with DefaultErrorHandler and JSON.
+func NewApp() *App {
+	a := &App{
+		ErrorHandler: DefaultErrorHandler(JSON[Error]{}),
+		Index:        Encodings[Route]{JSON[Route]{}},
+		mux:          http.NewServeMux(),
+	}
+	a.mux.Handle("/", http.HandlerFunc(a.index))
+	a.handler = a.mux
+	return a
+}
+
+// Use adds middlewares, which wrap the App in order, the first outermost.
+// The middlewares are applied here, not per request, so Use must not be called concurrently with serving.
+func (a *App) Use(middlewares ...Middleware) {
+	a.middlewares = append(a.middlewares, middlewares...)
+	var h http.Handler = a.mux
+	for i := len(a.middlewares) - 1; i >= 0; i-- {
+		h = a.middlewares[i](h)
+	}
+	a.handler = h
+}
+
+// Handle serves h under prefix, with the prefix stripped from the request path, like Ghost expects.
+// Like http.ServeMux, it panics if prefix is already registered.
+func (a *App) Handle(prefix string, h http.Handler) {
+	a.handle(prefix, "", h)
+}
+
+func (a *App) handle(prefix, resource string, h http.Handler) {
+	prefix = "/" + strings.Trim(prefix, "/") + "/"
+	if prefix == "//" {
+		panic("ghost: App can't serve resources at /")
+	}
+	a.mux.Handle(prefix, http.StripPrefix(strings.TrimSuffix(prefix, "/"), h))
+	a.routes = append(a.routes, Route{Path: prefix, Resource: resource})
+}
+
+// Routes returns the routes in registration order.
+func (a *App) Routes() []Route {
+	return append([]Route(nil), a.routes...)
+}
+
+func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.handler.ServeHTTP(w, r)
+}
+
+func (a *App) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		a.ErrorHandler(ErrNotFound).ServeHTTP(w, r)
+		return
+	}
+	allowed := []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodHead:
+		w = headResponse{w}
+	case http.MethodOptions:
+		_ = options(allowed)(w, r)
+		return
+	default:
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		a.ErrorHandler(ErrMethodNotAllowed).ServeHTTP(w, r)
+		return
+	}
+	enc, err := a.Index.Response(r)
+	if err == nil {
+		err = enc.EncodeList(w, a.Routes(), http.StatusOK)
+	}
+	if err != nil {
+		a.ErrorHandler(err).ServeHTTP(w, r)
+	}
+}
+
+// errorHandler calls the ErrorHandler of the App when handling, so that it can be set after registering.
+func (a *App) errorHandler(err error) http.Handler {
+	return a.ErrorHandler(err)
+}
+
+// Register serves the resources of store under prefix, like New, with encodings, or JSON if there are none.
+// Register requires PKey to be an integer.
+func Register[R Resource, Q Query, P PUintKey](a *App, prefix string, store Store[R, Q, P], encodings ...Encoding[R]) {
+	a.handle(prefix, resourceName[R](), Ghost[R, Q, P]{
+		Server:       WithPaging(NewNegotiatingServer[R, Q, P](NewHookStore(store), encodingsOr(encodings), PathIdentifier[P](UintPath[P]), NewQueryParser[Q]()), a.Paging),
+		Mux:          DefaultMux[R, Q],
+		ErrorHandler: a.errorHandler,
+	})
+}
+
+// RegisterS serves the resources of store under prefix, like NewS, with encodings, or JSON if there are none.
+// RegisterS requires PKey to be a string.
+func RegisterS[R Resource, Q Query, P PStrKey](a *App, prefix string, store Store[R, Q, P], encodings ...Encoding[R]) {
+	a.handle(prefix, resourceName[R](), Ghost[R, Q, P]{
+		Server:       WithPaging(NewNegotiatingServer[R, Q, P](NewHookStore(store), encodingsOr(encodings), PathIdentifier[P](StrPath[P]), NewQueryParser[Q]()), a.Paging),
+		Mux:          DefaultMux[R, Q],
+		ErrorHandler: a.errorHandler,
+	})
+}
+
+func encodingsOr[R Resource](encodings []Encoding[R]) Encodings[R] {
+	if len(encodings) == 0 {
+		return Encodings[R]{JSON[R]{}}
+	}
+	return encodings
+}
+
+func resourceName[R Resource]() string {
+	return reflect.TypeOf((*R)(nil)).Elem().Name()
+}
@@ -0,0 +1,165 @@
+package ghost_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mash/ghost"
+)
+
+func TestApp(t *testing.T) {
+	app := ghost.NewApp()
+	app.Index = ghost.Encodings[ghost.Route]{ghost.JSON[ghost.Route]{}, ghost.CSV[ghost.Route]{}}
+	app.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Middleware", "first")
+			next.ServeHTTP(w, r)
+		})
+	}, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Middleware", "second")
+			next.ServeHTTP(w, r)
+		})
+	})
+	ghost.Register[User](app, "/users", ghost.NewMapStore(User{}, SearchQuery{}, uint64(0)), ghost.JSON[User]{}, ghost.CSV[User]{})
+	ghost.RegisterS(app, "items/", ghost.NewMapStrStore(StrItem{}, SearchQuery{}, ""))
+	app.Handle("/accounts/", ghost.Nest(ghost.NewMapStore(User{}, SearchQuery{}, uint64(0)), map[string]http.Handler{
+		"posts": ghost.New(ghost.NewMapStore(Post{}, PostQuery{}, uint64(0))),
+	}))
+	// set after registering
+	app.ErrorHandler = ghost.ProblemErrorHandler(ghost.Encodings[ghost.Problem]{ghost.ProblemJSON{}})
+
+	tests := []struct {
+		name, method, path, accept, reqBody string
+		expectedCode                        int
+		expectedAllow                       string
+		expectedResBody                     string
+	}{
+		{
+			name:            "GET /",
+			method:          "GET",
+			path:            "/",
+			expectedCode:    200,
+			expectedResBody: `[{"path":"/users/","resource":"User"},{"path":"/items/","resource":"StrItem"},{"path":"/accounts/"}]`,
+		}, {
+			name:            "GET / as CSV",
+			method:          "GET",
+			path:            "/",
+			accept:          "text/csv",
+			expectedCode:    200,
+			expectedResBody: "path,resource\n/users/,User\n/items/,StrItem\n/accounts/,",
+		}, {
+			name:            "GET / as XML",
+			method:          "GET",
+			path:            "/",
+			accept:          "application/xml",
+			expectedCode:    406,
+			expectedResBody: `{"detail":"Not Acceptable","instance":"/","status":406,"title":"Not Acceptable","type":"about:blank"}`,
+		}, {
+			name:            "POST /users/",
+			method:          "POST",
+			path:            "/users/",
+			reqBody:         `{"Name":"John"}`,
+			expectedCode:    201,
+			expectedResBody: `{"Name":"John"}`,
+		}, {
+			name:            "GET /users/1 as CSV",
+			method:          "GET",
+			path:            "/users/1",
+			accept:          "text/csv",
+			expectedCode:    200,
+			expectedResBody: "Name\nJohn",
+		}, {
+			name:            "GET /users/2",
+			method:          "GET",
+			path:            "/users/2",
+			expectedCode:    404,
+			expectedResBody: `{"detail":"Not Found","instance":"/users/2","status":404,"title":"Not Found","type":"about:blank"}`,
+		}, {
+			name:            "POST /items/",
+			method:          "POST",
+			path:            "/items/",
+			reqBody:         `{"name":"Pen"}`,
+			expectedCode:    201,
+			expectedResBody: `{"id":"1","name":"Pen"}`,
+		}, {
+			name:            "POST /accounts/",
+			method:          "POST",
+			path:            "/accounts/",
+			reqBody:         `{"Name":"Bob"}`,
+			expectedCode:    201,
+			expectedResBody: `{"Name":"Bob"}`,
+		}, {
+			name:            "POST /accounts/1/posts/",
+			method:          "POST",
+			path:            "/accounts/1/posts/",
+			reqBody:         `{"title":"Hello"}`,
+			expectedCode:    201,
+			expectedResBody: `{"id":1,"user_id":1,"title":"Hello"}`,
+		}, {
+			name:            "GET /unknown",
+			method:          "GET",
+			path:            "/unknown",
+			expectedCode:    404,
+			expectedResBody: `{"detail":"Not Found","instance":"/unknown","status":404,"title":"Not Found","type":"about:blank"}`,
+		}, {
+			name:            "POST /",
+			method:          "POST",
+			path:            "/",
+			expectedCode:    405,
+			expectedAllow:   "GET, HEAD, OPTIONS",
+			expectedResBody: `{"detail":"Method Not Allowed","instance":"/","status":405,"title":"Method Not Allowed","type":"about:blank"}`,
+		}, {
+			name:         "HEAD /",
+			method:       "HEAD",
+			path:         "/",
+			expectedCode: 200,
+		}, {
+			name:          "OPTIONS /",
+			method:        "OPTIONS",
+			path:          "/",
+			expectedCode:  204,
+			expectedAllow: "GET, HEAD, OPTIONS",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.reqBody))
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			app.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := test.expectedAllow, w.Header().Get("Allow"); e != g {
+				t.Errorf("expected Allow %s, got %s", e, g)
+			}
+			if e, g := "first,second", strings.Join(w.Header().Values("X-Middleware"), ","); e != g {
+				t.Errorf("expected X-Middleware %s, got %s", e, g)
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
+
+func TestAppUse(t *testing.T) {
+	app := ghost.NewApp()
+	wrapped := 0
+	app.Use(func(next http.Handler) http.Handler {
+		wrapped++
+		return next
+	})
+	for i := 0; i < 3; i++ {
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	if e, g := 1, wrapped; e != g {
+		t.Errorf("expected the middleware to wrap %d times, got %d", e, g)
+	}
+}
@@ -0,0 +1,133 @@
+package ghost
+
+import (
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCORSAllowedHeaders are the request headers which CORS allows if AllowedHeaders is nil.
+var DefaultCORSAllowedHeaders = []string{"Accept", "Content-Type", "If-Match", "If-None-Match", IdempotencyKeyHeader}
+
+// DefaultCORSExposedHeaders are the response headers which CORS exposes if ExposedHeaders is nil.
+var DefaultCORSExposedHeaders = []string{"ETag", "Location", "Link", "X-Total-Count", "Idempotent-Replayed"}
+
+// CORS configures Cross-Origin Resource Sharing, see Handler.
+type CORS struct {
+	// AllowedOrigins are origins such as https://example.com, which may have * wildcards such as https://*.example.com.
+	// "*" allows any origin, without credentials.
+	AllowedOrigins []string
+	// AllowedOriginPatterns are regular expressions which allow the origins they match entirely.
+	AllowedOriginPatterns []*regexp.Regexp
+	// AllowedHeaders are the request headers which clients may send, DefaultCORSAllowedHeaders if nil.
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers which clients may read, DefaultCORSExposedHeaders if nil.
+	ExposedHeaders []string
+	// AllowCredentials allows requests with cookies and other credentials, which browsers refuse with any origin.
+	AllowCredentials bool
+	// Operations are the operations whose methods preflight requests allow.
+	// If nil, they are those of next if it is an Operator, such as Ghost, or AllOperations.
+	// Set them if next wraps a Ghost, such as Idempotent and http.StripPrefix do, as they aren't Operators.
+	Operations []Operation
+	// MaxAge is how long preflight responses may be cached, which is up to the client if 0.
+	MaxAge time.Duration
+}
+
+// Handler wraps next with CORS.
+// Preflight requests are responded without calling next, so they never touch the Store.
+// The allowed methods are those of Operations, for the collection or a resource like DefaultMux.
+// Requests from origins which aren't allowed are passed to next without CORS headers, which browsers reject.
+// Handler panics if AllowedOrigins has "*" and AllowCredentials is set, as allowing credentials from any origin
+// would let any site make requests with the cookies of the user.
+func (c CORS) Handler(next http.Handler) http.Handler {
+	origins := make([]*regexp.Regexp, 0, len(c.AllowedOrigins)+len(c.AllowedOriginPatterns))
+	anyOrigin := false
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			if c.AllowCredentials {
+				panic(`ghost: CORS can't allow credentials from any origin "*"`)
+			}
+			anyOrigin = true
+			continue
+		}
+		origins = append(origins, regexp.MustCompile("^"+strings.ReplaceAll(regexp.QuoteMeta(o), `\*`, `[^/]*`)+"$"))
+	}
+	for _, p := range c.AllowedOriginPatterns {
+		origins = append(origins, regexp.MustCompile("^(?:"+p.String()+")$"))
+	}
+	allowed := func(origin string) bool {
+		if anyOrigin {
+			return true
+		}
+		for _, o := range origins {
+			if o.MatchString(origin) {
+				return true
+			}
+		}
+		return false
+	}
+
+	ops := c.Operations
+	if ops == nil {
+		ops = AllOperations
+		if o, ok := next.(Operator); ok {
+			ops = o.Operations()
+		}
+	}
+	allowedHeaders := c.AllowedHeaders
+	if allowedHeaders == nil {
+		allowedHeaders = DefaultCORSAllowedHeaders
+	}
+	exposedHeaders := c.ExposedHeaders
+	if exposedHeaders == nil {
+		exposedHeaders = DefaultCORSExposedHeaders
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		h := w.Header()
+		if preflight {
+			h.Add("Vary", "Origin, Access-Control-Request-Method, Access-Control-Request-Headers")
+		} else {
+			h.Add("Vary", "Origin")
+		}
+		if origin == "" || !allowed(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if anyOrigin {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if c.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			if len(exposedHeaders) > 0 {
+				h.Set("Access-Control-Expose-Headers", strings.Join(exposedHeaders, ", "))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		_, f := path.Split(r.URL.Path)
+		h.Set("Access-Control-Allow-Methods", strings.Join(allow(ops, f == ""), ", "))
+		if len(allowedHeaders) > 0 {
+			h.Set("Access-Control-Allow-Headers", strings.Join(allowedHeaders, ", "))
+		}
+		if c.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,233 @@
+package ghost_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mash/ghost"
+)
+
+func TestCORS(t *testing.T) {
+	store := ghost.NewMapStore(User{}, SearchQuery{}, uint64(0))
+	cors := ghost.CORS{
+		AllowedOrigins:        []string{"https://example.com", "https://*.example.org"},
+		AllowedOriginPatterns: []*regexp.Regexp{regexp.MustCompile(`http://localhost:\d+`)},
+		AllowCredentials:      true,
+		MaxAge:                10 * time.Minute,
+	}
+	g := cors.Handler(ghost.New(store))
+	readOnly := ghost.CORS{AllowedOrigins: []string{"*"}}.Handler(ghost.New[User, SearchQuery, uint64](readOnlyStore{store}))
+
+	tests := []struct {
+		name, method, path, origin, requestMethod, reqBody string
+		handler                                            http.Handler
+		expectedCode                                       int
+		expectedHeader                                     map[string]string
+		expectedResBody                                    string
+	}{
+		{
+			name:          "preflight of the collection",
+			handler:       g,
+			method:        "OPTIONS",
+			path:          "/",
+			origin:        "https://example.com",
+			requestMethod: "POST",
+			expectedCode:  204,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin":      "https://example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "GET, HEAD, OPTIONS, POST",
+				"Access-Control-Allow-Headers":     "Accept, Content-Type, If-Match, If-None-Match, Idempotency-Key",
+				"Access-Control-Max-Age":           "600",
+				"Access-Control-Expose-Headers":    "",
+				"Vary":                             "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			},
+		}, {
+			name:          "preflight of a resource with a wildcard origin",
+			handler:       g,
+			method:        "OPTIONS",
+			path:          "/1",
+			origin:        "https://api.example.org",
+			requestMethod: "PUT",
+			expectedCode:  204,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin":  "https://api.example.org",
+				"Access-Control-Allow-Methods": "DELETE, GET, HEAD, OPTIONS, PATCH, PUT",
+			},
+		}, {
+			name:          "preflight with a regular expression origin",
+			handler:       g,
+			method:        "OPTIONS",
+			path:          "/1",
+			origin:        "http://localhost:3000",
+			requestMethod: "DELETE",
+			expectedCode:  204,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin": "http://localhost:3000",
+			},
+		}, {
+			name:          "preflight from a disallowed origin",
+			handler:       g,
+			method:        "OPTIONS",
+			path:          "/",
+			origin:        "https://example.com.evil.com",
+			requestMethod: "POST",
+			expectedCode:  204,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin":  "",
+				"Access-Control-Allow-Methods": "",
+			},
+		}, {
+			name:            "POST / from an allowed origin",
+			handler:         g,
+			method:          "POST",
+			path:            "/",
+			origin:          "https://example.com",
+			reqBody:         `{"Name":"John"}`,
+			expectedCode:    201,
+			expectedResBody: `{"Name":"John"}`,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin":   "https://example.com",
+				"Access-Control-Expose-Headers": "ETag, Location, Link, X-Total-Count, Idempotent-Replayed",
+				"Access-Control-Allow-Methods":  "",
+				"Vary":                          "Origin",
+			},
+		}, {
+			name:            "GET /1 from a disallowed origin",
+			handler:         g,
+			method:          "GET",
+			path:            "/1",
+			origin:          "https://example.net",
+			expectedCode:    200,
+			expectedResBody: `{"Name":"John"}`,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+		}, {
+			name:            "GET /2 without an origin",
+			handler:         g,
+			method:          "GET",
+			path:            "/2",
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+		}, {
+			name:            "OPTIONS / without preflight",
+			handler:         g,
+			method:          "OPTIONS",
+			path:            "/",
+			origin:          "https://example.com",
+			expectedCode:    204,
+			expectedResBody: ``,
+			expectedHeader: map[string]string{
+				"Allow":                        "GET, HEAD, OPTIONS, POST",
+				"Access-Control-Allow-Methods": "",
+			},
+		}, {
+			name:          "preflight of a read only store from any origin",
+			handler:       readOnly,
+			method:        "OPTIONS",
+			path:          "/",
+			origin:        "https://example.net",
+			requestMethod: "POST",
+			expectedCode:  204,
+			expectedHeader: map[string]string{
+				"Access-Control-Allow-Origin":      "*",
+				"Access-Control-Allow-Credentials": "",
+				"Access-Control-Allow-Methods":     "GET, HEAD, OPTIONS",
+				"Access-Control-Max-Age":           "",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.reqBody))
+			if test.origin != "" {
+				r.Header.Set("Origin", test.origin)
+			}
+			if test.requestMethod != "" {
+				r.Header.Set("Access-Control-Request-Method", test.requestMethod)
+			}
+			test.handler.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			for k, e := range test.expectedHeader {
+				if g := strings.Join(w.Header().Values(k), ", "); e != g {
+					t.Errorf("expected %s %s, got %s", k, e, g)
+				}
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
+
+func TestCORSPreflightSkipsHandler(t *testing.T) {
+	h := ghost.CORS{AllowedOrigins: []string{"*"}}.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("preflight reached the handler")
+	}))
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("OPTIONS", "/1", nil)
+	r.Header.Set("Origin", "https://example.com")
+	r.Header.Set("Access-Control-Request-Method", "DELETE")
+	h.ServeHTTP(w, r)
+
+	if e, g := 204, w.Code; e != g {
+		t.Errorf("expected %d, got %d", e, g)
+	}
+	if e, g := "DELETE, GET, HEAD, OPTIONS, PATCH, PUT", w.Header().Get("Access-Control-Allow-Methods"); e != g {
+		t.Errorf("expected %s, got %s", e, g)
+	}
+}
+
+func TestCORSWrappedOperations(t *testing.T) {
+	store := readOnlyStore{ghost.NewMapStore(User{}, SearchQuery{}, uint64(0))}
+	idempotent := ghost.Idempotent(ghost.New[User, SearchQuery, uint64](store), ghost.NewMapIdempotencyStore(0), nil)
+	tests := []struct {
+		name            string
+		cors            ghost.CORS
+		expectedMethods string
+	}{
+		{
+			name:            "unknown operations",
+			cors:            ghost.CORS{AllowedOrigins: []string{"*"}},
+			expectedMethods: "GET, HEAD, OPTIONS, POST",
+		}, {
+			name:            "explicit operations",
+			cors:            ghost.CORS{AllowedOrigins: []string{"*"}, Operations: store.Operations()},
+			expectedMethods: "GET, HEAD, OPTIONS",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("OPTIONS", "/", nil)
+			r.Header.Set("Origin", "https://example.com")
+			r.Header.Set("Access-Control-Request-Method", "GET")
+			test.cors.Handler(idempotent).ServeHTTP(w, r)
+
+			if e, g := test.expectedMethods, w.Header().Get("Access-Control-Allow-Methods"); e != g {
+				t.Errorf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
+
+func TestCORSAnyOriginWithCredentials(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	ghost.CORS{AllowedOrigins: []string{"https://example.com", "*"}, AllowCredentials: true}.Handler(http.NotFoundHandler())
+}
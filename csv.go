@@ -0,0 +1,343 @@
+package ghost
+
+import (
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// CSV is an Encoding and a StreamEncoding.
+// R must be a struct. The columns are its exported fields, named by the csv struct tag,
+// or by the json struct tag if there is none, or else by the field name.
+// Fields tagged with "-" are skipped, and embedded structs are flattened.
+//
+// Values are formatted by encoding.TextMarshaler if implemented,
+// strings, numbers and bools as is, nil pointers as empty, and the others as JSON, with null as empty.
+// The first row is the header, which is written even if there are no resources.
+//
+// Spreadsheets run cells which start with =, +, -, @, a tab or a carriage return as formulas,
+// so text values which do are prefixed with ', which Decode removes, unless AllowFormulas is set.
+type CSV[R Resource] struct {
+	AllowFormulas bool
+}
+
+func (c CSV[R]) MediaType() string {
+	return "text/csv"
+}
+
+func (c CSV[R]) Encode(w http.ResponseWriter, r R, code int) error {
+	return c.EncodeList(w, []R{r}, code)
+}
+
+func (c CSV[R]) EncodeList(w http.ResponseWriter, rs []R, code int) error {
+	return c.EncodeStream(w, code, func(yield func(R) error) error {
+		for _, r := range rs {
+			if err := yield(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c CSV[R]) EncodeStream(w http.ResponseWriter, code int, stream func(func(R) error) error) error {
+	columns, err := csvColumns(reflect.TypeOf((*R)(nil)).Elem())
+	if err != nil {
+		return err
+	}
+	escape := !c.AllowFormulas
+	var cw *csv.Writer
+	start := func() error {
+		if cw != nil {
+			return nil
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(code)
+		cw = csv.NewWriter(w)
+		header := make([]string, len(columns))
+		for i, col := range columns {
+			header[i] = col.name
+		}
+		return cw.Write(header)
+	}
+	record := make([]string, len(columns))
+	err = stream(func(r R) error {
+		if err := start(); err != nil {
+			return err
+		}
+		v := reflect.ValueOf(&r).Elem()
+		for v.Kind() == reflect.Pointer && !v.IsNil() {
+			v = v.Elem()
+		}
+		for i, col := range columns {
+			if v.Kind() == reflect.Pointer {
+				// a nil resource
+				record[i] = ""
+				continue
+			}
+			f, err := v.FieldByIndexErr(col.index)
+			if err != nil {
+				// a nil embedded pointer
+				record[i] = ""
+				continue
+			}
+			if record[i], err = csvFormat(f, escape); err != nil {
+				return fmt.Errorf("column %s: %w", col.name, err)
+			}
+		}
+		return cw.Write(record)
+	})
+	if err != nil {
+		return err
+	}
+	if err := start(); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (c CSV[R]) EncodeEmpty(w http.ResponseWriter, code int) error {
+	w.Header().Set("Content-Type", "text/csv")
+	w.WriteHeader(code)
+	return nil
+}
+
+// Decode decodes a header and a single row into a resource.
+// Columns which don't match any field are ignored.
+func (c CSV[R]) Decode(r *http.Request) (R, error) {
+	var rr R
+	columns, err := csvColumns(reflect.TypeOf(rr))
+	if err != nil {
+		return rr, err
+	}
+	byName := make(map[string]csvColumn, len(columns))
+	for _, col := range columns {
+		byName[col.name] = col
+	}
+
+	cr := csv.NewReader(r.Body)
+	header, err := cr.Read()
+	if err != nil {
+		return rr, err
+	}
+	record, err := cr.Read()
+	if err != nil {
+		return rr, err
+	}
+	v := reflect.ValueOf(&rr).Elem()
+	for v.Kind() == reflect.Pointer {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	for i, name := range header {
+		col, ok := byName[name]
+		if !ok {
+			continue
+		}
+		f, err := fieldByIndexAlloc(v, col.index)
+		if err != nil {
+			return rr, err
+		}
+		if err := csvParse(f, record[i], !c.AllowFormulas); err != nil {
+			return rr, fmt.Errorf("column %s: %w", name, err)
+		}
+	}
+	return rr, nil
+}
+
+type csvColumn struct {
+	name  string
+	index []int
+}
+
+// csvColumns returns the columns of the struct t, or of the struct which t points to, in field order.
+// Fields of embedded structs are flattened unless shadowed, as in encoding/json.
+func csvColumns(t reflect.Type) ([]csvColumn, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("csv: %s is not a struct", t)
+	}
+	return csvFields(t, nil), nil
+}
+
+func csvFields(t reflect.Type, index []int) []csvColumn {
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if name := csvName(f); name != "" && !(f.Anonymous && csvTagName(f) == "") {
+			names[name] = true
+		}
+	}
+
+	var columns []csvColumn
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := csvName(f)
+		if name == "" {
+			continue
+		}
+		idx := append(append([]int{}, index...), i)
+		if f.Anonymous && csvTagName(f) == "" {
+			et := f.Type
+			if et.Kind() == reflect.Pointer {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				for _, col := range csvFields(et, idx) {
+					if !names[col.name] {
+						columns = append(columns, col)
+					}
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		columns = append(columns, csvColumn{name: name, index: idx})
+	}
+	return columns
+}
+
+// csvName returns the column name of f, or empty if f is skipped.
+func csvName(f reflect.StructField) string {
+	name := csvTagName(f)
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name
+}
+
+func csvTagName(f reflect.StructField) string {
+	for _, key := range []string{"csv", "json"} {
+		if tag, ok := f.Tag.Lookup(key); ok {
+			name, _, _ := strings.Cut(tag, ",")
+			if name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// csvFormat formats an addressable value, escaping text which spreadsheets would run as a formula if escape is set.
+func csvFormat(v reflect.Value, escape bool) (string, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if v.CanAddr() {
+		if m, ok := v.Addr().Interface().(encoding.TextMarshaler); ok {
+			b, err := m.MarshalText()
+			return csvEscape(string(b), escape), err
+		}
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		return csvEscape(string(b), escape), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return csvEscape(v.String(), escape), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	b, err := json.Marshal(v.Interface())
+	if string(b) == "null" {
+		return "", err
+	}
+	return string(b), err
+}
+
+// csvParse sets the addressable value v to s, the reverse of csvFormat.
+// Empty values are parsed as zero values.
+func csvParse(v reflect.Value, s string, unescape bool) error {
+	if s == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(csvUnescape(s, unescape)))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(csvUnescape(s, unescape))
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		v.SetBool(b)
+		return err
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		v.SetInt(i)
+		return err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		v.SetUint(i)
+		return err
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		v.SetFloat(f)
+		return err
+	}
+	return json.Unmarshal([]byte(s), v.Addr().Interface())
+}
+
+// csvFormulaPrefixes are the first characters of the cells which spreadsheets run as formulas.
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvEscape prefixes s with ' if escape is set and spreadsheets would run s as a formula.
+func csvEscape(s string, escape bool) string {
+	if escape && s != "" && strings.IndexByte(csvFormulaPrefixes, s[0]) >= 0 {
+		return "'" + s
+	}
+	return s
+}
+
+// csvUnescape removes the prefix which csvEscape adds if unescape is set.
+func csvUnescape(s string, unescape bool) string {
+	if unescape && len(s) > 1 && s[0] == '\'' && strings.IndexByte(csvFormulaPrefixes, s[1]) >= 0 {
+		return s[1:]
+	}
+	return s
+}
+
+// fieldByIndexAlloc is like reflect.Value.FieldByIndex but allocates nil embedded pointers.
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return v, fmt.Errorf("csv: can't set embedded pointer to unexported struct %s", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
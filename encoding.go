@@ -2,9 +2,15 @@ package ghost
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"mime"
 	"net/http"
+	"strings"
 )
 
+// Encoding encodes resources to responses and decodes them from requests.
+// Encodings which implement MediaTyper can be negotiated, see Encodings.
 type Encoding[R Resource] interface {
 	Encode(http.ResponseWriter, R, int) error
 	EncodeList(http.ResponseWriter, []R, int) error
@@ -13,7 +19,22 @@ type Encoding[R Resource] interface {
 }
 
 // JSON is an Encoding.
-type JSON[R Resource] struct{}
+// Its zero value decodes leniently, like encoding/json, the fields make the decoding strict.
+type JSON[R Resource] struct {
+	// MaxBytes limits the size of request bodies, larger ones are ErrRequestEntityTooLarge. Zero means no limit.
+	MaxBytes int64
+	// DisallowUnknownFields rejects request bodies with object keys which don't match any field of R.
+	DisallowUnknownFields bool
+	// DisallowTrailingData rejects request bodies with data after the JSON value.
+	DisallowTrailingData bool
+	// RequireContentType rejects request bodies without a JSON Content-Type, such as application/json
+	// or application/merge-patch+json, with ErrUnsupportedMediaType.
+	RequireContentType bool
+}
+
+func (j JSON[R]) MediaType() string {
+	return "application/json"
+}
 
 func (j JSON[R]) Encode(w http.ResponseWriter, r R, code int) error {
 	w.Header().Set("Content-Type", "application/json")
@@ -32,12 +53,164 @@ func (j JSON[R]) EncodeList(w http.ResponseWriter, rs []R, code int) error {
 func (j JSON[R]) EncodeEmpty(w http.ResponseWriter, code int) error {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
+	if !bodyAllowed(code) {
+		return nil
+	}
 	enc := json.NewEncoder(w)
 	return enc.Encode(map[string]interface{}{})
 }
 
 func (j JSON[R]) Decode(r *http.Request) (R, error) {
+	if j.RequireContentType && !isJSON(r.Header.Get("Content-Type")) {
+		var rr R
+		return rr, ErrUnsupportedMediaType
+	}
+	return j.decode(j.limit(r.Body))
+}
+
+func (j JSON[R]) decode(body io.Reader) (R, error) {
+	var rr R
+	if err := j.decodeValue(body, &rr); err != nil {
+		return rr, err
+	}
+	return rr, nil
+}
+
+// decodeValue decodes body into v, which needn't be R, as strictly as the fields tell.
+func (j JSON[R]) decodeValue(body io.Reader, v any) error {
+	dec := json.NewDecoder(body)
+	if j.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if j.DisallowTrailingData {
+		if _, err := dec.Token(); err != io.EOF {
+			var e Error
+			if errors.As(err, &e) {
+				return err
+			}
+			return errors.New("unexpected data after the JSON value")
+		}
+	}
+	return nil
+}
+
+// limit limits body to MaxBytes.
+func (j JSON[R]) limit(body io.Reader) io.Reader {
+	if j.MaxBytes <= 0 {
+		return body
+	}
+	return &maxBytesReader{r: body, n: j.MaxBytes}
+}
+
+// maxBytesReader is like http.MaxBytesReader, but returns ErrRequestEntityTooLarge.
+type maxBytesReader struct {
+	r io.Reader
+	// n is the number of bytes left
+	n int64
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// read one more byte to know if the body is too large
+	if int64(len(p)) > m.n+1 {
+		p = p[:m.n+1]
+	}
+	n, err := m.r.Read(p)
+	if int64(n) <= m.n {
+		m.n -= int64(n)
+		return n, err
+	}
+	n = int(m.n)
+	m.n = 0
+	return n, ErrRequestEntityTooLarge
+}
+
+// isJSON reports whether the media type of contentType is JSON.
+func isJSON(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+// StreamEncoding is implemented by Encodings which can encode a list as it is being read from the store.
+// stream calls yield with each resource in order.
+// The response header must not be written before the first resource is yielded or stream returns,
+// so that errors of the store before the first resource can still be handled.
+type StreamEncoding[R Resource] interface {
+	EncodeStream(w http.ResponseWriter, code int, stream func(yield func(R) error) error) error
+}
+
+// NDJSON is an Encoding and a StreamEncoding.
+// Lists are encoded as a JSON text per line, see https://github.com/ndjson/ndjson-spec.
+type NDJSON[R Resource] struct{}
+
+func (n NDJSON[R]) MediaType() string {
+	return "application/x-ndjson"
+}
+
+func (n NDJSON[R]) Encode(w http.ResponseWriter, r R, code int) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(code)
+	return json.NewEncoder(w).Encode(r)
+}
+
+func (n NDJSON[R]) EncodeList(w http.ResponseWriter, rs []R, code int) error {
+	return n.EncodeStream(w, code, func(yield func(R) error) error {
+		for _, r := range rs {
+			if err := yield(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (n NDJSON[R]) EncodeStream(w http.ResponseWriter, code int, stream func(func(R) error) error) error {
+	var enc *json.Encoder
+	start := func() {
+		if enc == nil {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(code)
+			enc = json.NewEncoder(w)
+		}
+	}
+	err := stream(func(r R) error {
+		start()
+		return enc.Encode(r)
+	})
+	if err != nil {
+		return err
+	}
+	start()
+	return nil
+}
+
+func (n NDJSON[R]) EncodeEmpty(w http.ResponseWriter, code int) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(code)
+	return nil
+}
+
+func (n NDJSON[R]) Decode(r *http.Request) (R, error) {
 	var rr R
 	err := json.NewDecoder(r.Body).Decode(&rr)
 	return rr, err
 }
+
+// bodyAllowed reports whether a response with the status code may have a body.
+func bodyAllowed(code int) bool {
+	switch {
+	case code >= 100 && code <= 199:
+		return false
+	case code == http.StatusNoContent, code == http.StatusNotModified:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+package cbor
+
+import (
+	"net/http"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/mash/ghost"
+)
+
+// Encoding is a ghost.Encoding of CBOR.
+// It honors the json struct tags, unless a field has a cbor struct tag, and encodes ghost.Errors as a map like ghost.JSON does.
+type Encoding[R ghost.Resource] struct{}
+
+func (c Encoding[R]) MediaType() string {
+	return "application/cbor"
+}
+
+func (c Encoding[R]) Encode(w http.ResponseWriter, r R, code int) error {
+	w.Header().Set("Content-Type", "application/cbor")
+	w.WriteHeader(code)
+	return cbor.NewEncoder(w).Encode(value(r))
+}
+
+func (c Encoding[R]) EncodeList(w http.ResponseWriter, rs []R, code int) error {
+	w.Header().Set("Content-Type", "application/cbor")
+	w.WriteHeader(code)
+	// encode an empty array instead of null
+	vs := make([]any, len(rs))
+	for i, r := range rs {
+		vs[i] = value(r)
+	}
+	return cbor.NewEncoder(w).Encode(vs)
+}
+
+func (c Encoding[R]) EncodeEmpty(w http.ResponseWriter, code int) error {
+	w.Header().Set("Content-Type", "application/cbor")
+	w.WriteHeader(code)
+	return nil
+}
+
+func (c Encoding[R]) Decode(r *http.Request) (R, error) {
+	var rr R
+	err := cbor.NewDecoder(r.Body).Decode(&rr)
+	return rr, err
+}
+
+// value returns what to encode for r.
+func value(r any) any {
+	if e, ok := r.(ghost.Error); ok {
+		return e.Members()
+	}
+	return r
+}
@@ -0,0 +1,62 @@
+package msgpack
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/mash/ghost"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Encoding is a ghost.Encoding of MessagePack.
+// It honors the json struct tags, like ghost.JSON, and encodes ghost.Errors as a map like ghost.JSON does.
+type Encoding[R ghost.Resource] struct{}
+
+func (m Encoding[R]) MediaType() string {
+	return "application/msgpack"
+}
+
+func (m Encoding[R]) Encode(w http.ResponseWriter, r R, code int) error {
+	w.Header().Set("Content-Type", "application/msgpack")
+	w.WriteHeader(code)
+	return m.encoder(w).Encode(value(r))
+}
+
+func (m Encoding[R]) EncodeList(w http.ResponseWriter, rs []R, code int) error {
+	w.Header().Set("Content-Type", "application/msgpack")
+	w.WriteHeader(code)
+	// encode an empty array instead of nil
+	vs := make([]any, len(rs))
+	for i, r := range rs {
+		vs[i] = value(r)
+	}
+	return m.encoder(w).Encode(vs)
+}
+
+func (m Encoding[R]) EncodeEmpty(w http.ResponseWriter, code int) error {
+	w.Header().Set("Content-Type", "application/msgpack")
+	w.WriteHeader(code)
+	return nil
+}
+
+func (m Encoding[R]) Decode(r *http.Request) (R, error) {
+	var rr R
+	dec := msgpack.NewDecoder(r.Body)
+	dec.SetCustomStructTag("json")
+	err := dec.Decode(&rr)
+	return rr, err
+}
+
+func (m Encoding[R]) encoder(w io.Writer) *msgpack.Encoder {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc
+}
+
+// value returns what to encode for r.
+func value(r any) any {
+	if e, ok := r.(ghost.Error); ok {
+		return e.Members()
+	}
+	return r
+}
@@ -0,0 +1,115 @@
+package xml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"sort"
+
+	"github.com/mash/ghost"
+)
+
+// Encoding is a ghost.Encoding of XML.
+// Lists are wrapped in a root element named ListElement, or "list" if it is empty.
+// ghost.Errors are encoded as <error>message</error>, with an element for each of the extensions of their Err after the message.
+//
+// Resources are encoded before the response header is written, so that resources which encoding/xml can't encode,
+// such as those with map fields, are errors which the error handler can respond with.
+type Encoding[R ghost.Resource] struct {
+	ListElement string
+}
+
+func (x Encoding[R]) MediaType() string {
+	return "application/xml"
+}
+
+func (x Encoding[R]) Encode(w http.ResponseWriter, r R, code int) error {
+	var b bytes.Buffer
+	if err := xml.NewEncoder(&b).Encode(value(r)); err != nil {
+		return err
+	}
+	return x.write(w, b.Bytes(), code)
+}
+
+func (x Encoding[R]) EncodeList(w http.ResponseWriter, rs []R, code int) error {
+	name := x.ListElement
+	if name == "" {
+		name = "list"
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	var b bytes.Buffer
+	enc := xml.NewEncoder(&b)
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, r := range rs {
+		if err := enc.Encode(value(r)); err != nil {
+			return err
+		}
+	}
+	if err := enc.EncodeToken(start.End()); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	return x.write(w, b.Bytes(), code)
+}
+
+func (x Encoding[R]) EncodeEmpty(w http.ResponseWriter, code int) error {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(code)
+	return nil
+}
+
+func (x Encoding[R]) Decode(r *http.Request) (R, error) {
+	var rr R
+	err := xml.NewDecoder(r.Body).Decode(&rr)
+	return rr, err
+}
+
+func (x Encoding[R]) write(w http.ResponseWriter, body []byte, code int) error {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(code)
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+// errorElement is the XML encoding of a ghost.Error.
+type errorElement ghost.Error
+
+// MarshalXML encodes the message of the error as the character data of the element,
+// followed by an element for each of the other members of the error, in the order of their names.
+func (e errorElement) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "error"}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	members := ghost.Error(e).Members()
+	if err := enc.EncodeToken(xml.CharData(members["error"].(string))); err != nil {
+		return err
+	}
+	delete(members, "error")
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := enc.EncodeElement(members[name], xml.StartElement{Name: xml.Name{Local: name}}); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// value returns what to encode for r.
+func value(r any) any {
+	if e, ok := r.(ghost.Error); ok {
+		return errorElement(e)
+	}
+	return r
+}
@@ -0,0 +1,124 @@
+package xml_test
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mash/ghost"
+	"github.com/mash/ghost/encoding/xml"
+)
+
+type User struct {
+	Name string
+}
+
+type SearchQuery struct {
+	Name string
+}
+
+type Tagged struct {
+	Name string
+	Tags map[string]string
+}
+
+func TestEncoding(t *testing.T) {
+	g := ghost.Ghost[User, SearchQuery, uint64]{
+		Server: ghost.NewNegotiatingServer[User, SearchQuery, uint64](
+			ghost.NewMapStore(User{}, SearchQuery{}, uint64(0)),
+			ghost.Encodings[User]{ghost.JSON[User]{}, xml.Encoding[User]{ListElement: "users"}},
+			ghost.PathIdentifier[uint64](ghost.UintPath[uint64]),
+			ghost.NewQueryParser[SearchQuery](),
+		),
+		Mux:          ghost.DefaultMux[User, SearchQuery],
+		ErrorHandler: ghost.NegotiatingErrorHandler(ghost.Encodings[ghost.Error]{ghost.JSON[ghost.Error]{}, xml.Encoding[ghost.Error]{}}),
+	}
+	tagged := ghost.Ghost[Tagged, SearchQuery, uint64]{
+		Server: ghost.NewServer[Tagged, SearchQuery, uint64](
+			ghost.NewMapStore(Tagged{}, SearchQuery{}, uint64(0)),
+			xml.Encoding[Tagged]{},
+			ghost.PathIdentifier[uint64](ghost.UintPath[uint64]),
+			ghost.NewQueryParser[SearchQuery](),
+		),
+		Mux:          ghost.DefaultMux[Tagged, SearchQuery],
+		ErrorHandler: ghost.DefaultErrorHandler(xml.Encoding[ghost.Error]{}),
+	}
+
+	tests := []struct {
+		name, method, path, reqBody string
+		expectedCode                int
+		expectedResBody             string
+	}{
+		{
+			name:            "POST /",
+			method:          "POST",
+			path:            "/",
+			reqBody:         `<User><Name>John</Name></User>`,
+			expectedCode:    201,
+			expectedResBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<User><Name>John</Name></User>`,
+		}, {
+			name:            "POST / again",
+			method:          "POST",
+			path:            "/",
+			reqBody:         `<User><Name>Paul</Name></User>`,
+			expectedCode:    201,
+			expectedResBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<User><Name>Paul</Name></User>`,
+		}, {
+			name:            "GET /",
+			method:          "GET",
+			path:            "/",
+			expectedCode:    200,
+			expectedResBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<users><User><Name>John</Name></User><User><Name>Paul</Name></User></users>`,
+		}, {
+			name:            "GET /3",
+			method:          "GET",
+			path:            "/3",
+			expectedCode:    404,
+			expectedResBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<error>Not Found</error>`,
+		}, {
+			name:            "DELETE /1",
+			method:          "DELETE",
+			path:            "/1",
+			expectedCode:    204,
+			expectedResBody: ``,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			var body io.Reader
+			if test.reqBody != "" {
+				body = strings.NewReader(test.reqBody)
+			}
+			r := httptest.NewRequest(test.method, test.path, body)
+			r.Header.Set("Content-Type", "application/xml")
+			r.Header.Set("Accept", "application/xml")
+			g.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := "application/xml", w.Header().Get("Content-Type"); e != g {
+				t.Errorf("expected Content-Type %s, got %s", e, g)
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+
+	// encoding/xml can't encode maps, which are errors handled before anything is written
+	t.Run("map field", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`<Tagged><Name>Pen</Name></Tagged>`))
+		tagged.ServeHTTP(w, r)
+
+		if e, g := 500, w.Code; e != g {
+			t.Errorf("expected %d, got %d", e, g)
+		}
+		if e, g := `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+`<error>xml: unsupported type: map[string]string</error>`, strings.TrimSpace(w.Body.String()); e != g {
+			t.Fatalf("expected %s, got %s", e, g)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package yaml
+
+import (
+	"net/http"
+
+	"github.com/mash/ghost"
+	"gopkg.in/yaml.v3"
+)
+
+// Encoding is a ghost.Encoding of YAML.
+// ghost.Errors are encoded as a mapping like ghost.JSON does.
+type Encoding[R ghost.Resource] struct{}
+
+func (y Encoding[R]) MediaType() string {
+	return "application/yaml"
+}
+
+func (y Encoding[R]) Encode(w http.ResponseWriter, r R, code int) error {
+	return y.encode(w, value(r), code)
+}
+
+func (y Encoding[R]) EncodeList(w http.ResponseWriter, rs []R, code int) error {
+	vs := make([]any, len(rs))
+	for i, r := range rs {
+		vs[i] = value(r)
+	}
+	return y.encode(w, vs, code)
+}
+
+func (y Encoding[R]) EncodeEmpty(w http.ResponseWriter, code int) error {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(code)
+	return nil
+}
+
+func (y Encoding[R]) Decode(r *http.Request) (R, error) {
+	var rr R
+	err := yaml.NewDecoder(r.Body).Decode(&rr)
+	return rr, err
+}
+
+func (y Encoding[R]) encode(w http.ResponseWriter, v any, code int) error {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(code)
+	enc := yaml.NewEncoder(w)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// value returns what to encode for r.
+func value(r any) any {
+	if e, ok := r.(ghost.Error); ok {
+		return e.Members()
+	}
+	return r
+}
@@ -0,0 +1,101 @@
+package yaml_test
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mash/ghost"
+	"github.com/mash/ghost/encoding/yaml"
+)
+
+type User struct {
+	Name string
+}
+
+type SearchQuery struct {
+	Name string
+}
+
+func TestEncoding(t *testing.T) {
+	g := ghost.Ghost[User, SearchQuery, uint64]{
+		Server: ghost.NewNegotiatingServer[User, SearchQuery, uint64](
+			ghost.NewMapStore(User{}, SearchQuery{}, uint64(0)),
+			ghost.Encodings[User]{ghost.JSON[User]{}, yaml.Encoding[User]{}},
+			ghost.PathIdentifier[uint64](ghost.UintPath[uint64]),
+			ghost.NewQueryParser[SearchQuery](),
+		),
+		Mux:          ghost.DefaultMux[User, SearchQuery],
+		ErrorHandler: ghost.NegotiatingErrorHandler(ghost.Encodings[ghost.Error]{ghost.JSON[ghost.Error]{}, yaml.Encoding[ghost.Error]{}}),
+	}
+
+	tests := []struct {
+		name, method, path, reqBody string
+		expectedCode                int
+		expectedResBody             string
+	}{
+		{
+			name:            "GET / empty",
+			method:          "GET",
+			path:            "/",
+			expectedCode:    200,
+			expectedResBody: `[]`,
+		}, {
+			name:            "POST /",
+			method:          "POST",
+			path:            "/",
+			reqBody:         "name: John\n",
+			expectedCode:    201,
+			expectedResBody: `name: John`,
+		}, {
+			name:            "POST / again",
+			method:          "POST",
+			path:            "/",
+			reqBody:         "name: Paul\n",
+			expectedCode:    201,
+			expectedResBody: `name: Paul`,
+		}, {
+			name:            "GET /",
+			method:          "GET",
+			path:            "/",
+			expectedCode:    200,
+			expectedResBody: "- name: John\n- name: Paul",
+		}, {
+			name:            "GET /3",
+			method:          "GET",
+			path:            "/3",
+			expectedCode:    404,
+			expectedResBody: `error: Not Found`,
+		}, {
+			name:            "DELETE /1",
+			method:          "DELETE",
+			path:            "/1",
+			expectedCode:    204,
+			expectedResBody: ``,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			var body io.Reader
+			if test.reqBody != "" {
+				body = strings.NewReader(test.reqBody)
+			}
+			r := httptest.NewRequest(test.method, test.path, body)
+			r.Header.Set("Content-Type", "application/yaml")
+			r.Header.Set("Accept", "application/yaml")
+			g.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := "application/yaml", w.Header().Get("Content-Type"); e != g {
+				t.Errorf("expected Content-Type %s, got %s", e, g)
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
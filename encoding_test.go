@@ -0,0 +1,457 @@
+package ghost_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mash/ghost"
+)
+
+type Row struct {
+	ID      uint64            `json:"id"`
+	Name    string            `csv:"full_name" json:"name"`
+	Email   *string           `json:"email"`
+	Tags    []string          `json:"tags"`
+	Secret  string            `json:"-"`
+	Created time.Time         `json:"created"`
+	Meta    map[string]string `json:"meta,omitempty"`
+}
+
+func (r *Row) PKey() uint64 {
+	return r.ID
+}
+
+func (r *Row) SetPKey(id uint64) {
+	r.ID = id
+}
+
+func (r *Row) AfterList(ctx context.Context, q *SearchQuery, rs []Row) error {
+	for i := range rs {
+		rs[i].Name = strings.ToUpper(rs[i].Name)
+	}
+	return nil
+}
+
+func (r *Row) AfterStream(ctx context.Context, q *SearchQuery, rr *Row) error {
+	rr.Name = strings.ToUpper(rr.Name)
+	return nil
+}
+
+// Ranked needs the whole list in AfterList.
+type Ranked struct {
+	Name string `json:"name"`
+	Of   int    `json:"of"`
+}
+
+func (r *Ranked) AfterList(ctx context.Context, q *SearchQuery, rs []Ranked) error {
+	for i := range rs {
+		rs[i].Of = len(rs)
+	}
+	return nil
+}
+
+// brokenStream fails after yielding the first resource.
+type brokenStream struct {
+	ghost.Store[Item, SearchQuery, uint64]
+}
+
+func (s brokenStream) Stream(ctx context.Context, q *SearchQuery, yield func(Item) error) error {
+	if err := yield(Item{Name: "a"}); err != nil {
+		return err
+	}
+	return errors.New("connection reset")
+}
+
+func TestStreamHooks(t *testing.T) {
+	store := ghost.NewHookStore(ghost.NewMapStore(Ranked{}, SearchQuery{}, uint64(0)))
+	for _, name := range []string{"a", "b"} {
+		if err := store.Create(context.Background(), &Ranked{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var of []int
+	err := store.(ghost.Streamer[Ranked, SearchQuery]).Stream(context.Background(), &SearchQuery{}, func(r Ranked) error {
+		of = append(of, r.Of)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, g := "[2 2]", fmt.Sprint(of); e != g {
+		t.Errorf("expected %s, got %s", e, g)
+	}
+}
+
+func TestStreamError(t *testing.T) {
+	var logs bytes.Buffer
+	g := ghost.Ghost[Item, SearchQuery, uint64]{
+		Server: ghost.NewNegotiatingServer[Item, SearchQuery, uint64](
+			brokenStream{ghost.NewMapStore(Item{}, SearchQuery{}, uint64(0))},
+			ghost.Encodings[Item]{ghost.JSON[Item]{}, ghost.NDJSON[Item]{}},
+			ghost.PathIdentifier[uint64](ghost.UintPath[uint64]),
+			ghost.NewQueryParser[SearchQuery](),
+		),
+		Mux:          ghost.DefaultMux[Item, SearchQuery],
+		ErrorHandler: ghost.ProductionErrorHandler(ghost.DefaultErrorHandler(ghost.JSON[ghost.Error]{}), log.New(&logs, "", 0)),
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "application/x-ndjson")
+
+	func() {
+		defer func() {
+			if e, g := any(http.ErrAbortHandler), recover(); e != g {
+				t.Errorf("expected to abort with %v, got %v", e, g)
+			}
+		}()
+		g.ServeHTTP(w, r)
+	}()
+	if e, g := `{"id":0,"name":"a"}`, strings.TrimSpace(w.Body.String()); e != g {
+		t.Errorf("expected %s, got %s", e, g)
+	}
+	if e, g := "GET /: streaming: connection reset\n", logs.String(); e != g {
+		t.Errorf("expected log %q, got %q", e, g)
+	}
+}
+
+func TestStreamEncodings(t *testing.T) {
+	store := ghost.NewHookStore(ghost.NewMapStore(Row{}, SearchQuery{}, uint64(0)))
+	g := ghost.Ghost[Row, SearchQuery, uint64]{
+		Server: ghost.NewNegotiatingServer[Row, SearchQuery, uint64](
+			store,
+			ghost.Encodings[Row]{ghost.JSON[Row]{}, ghost.NDJSON[Row]{}, ghost.CSV[Row]{}},
+			ghost.PathIdentifier[uint64](ghost.UintPath[uint64]),
+			ghost.NewQueryParser[SearchQuery](),
+		),
+		Mux:          ghost.DefaultMux[Row, SearchQuery],
+		ErrorHandler: ghost.DefaultErrorHandler(ghost.JSON[ghost.Error]{}),
+	}
+
+	tests := []struct {
+		name, method, path, contentType, accept, reqBody string
+		expectedCode                                     int
+		expectedContentType                              string
+		expectedResBody                                  string
+	}{
+		{
+			name:                "GET / csv without resources",
+			method:              "GET",
+			path:                "/",
+			accept:              "text/csv",
+			expectedCode:        200,
+			expectedContentType: "text/csv",
+			expectedResBody:     `id,full_name,email,tags,created,meta`,
+		}, {
+			name:                "POST / csv",
+			method:              "POST",
+			path:                "/",
+			contentType:         "text/csv",
+			accept:              "text/csv",
+			reqBody:             "id,full_name,email,tags,created,unknown\n,John,john@example.com,\"[\"\"a\"\",\"\"b\"\"]\",2022-01-02T03:04:05Z,x\n",
+			expectedCode:        201,
+			expectedContentType: "text/csv",
+			expectedResBody:     "id,full_name,email,tags,created,meta\n1,John,john@example.com,\"[\"\"a\"\",\"\"b\"\"]\",2022-01-02T03:04:05Z,",
+		}, {
+			name:                "POST / ndjson",
+			method:              "POST",
+			path:                "/",
+			contentType:         "application/x-ndjson",
+			accept:              "application/x-ndjson",
+			reqBody:             `{"name":"Paul","created":"2022-01-02T03:04:05Z"}`,
+			expectedCode:        201,
+			expectedContentType: "application/x-ndjson",
+			expectedResBody:     `{"id":2,"name":"Paul","email":null,"tags":null,"created":"2022-01-02T03:04:05Z"}`,
+		}, {
+			name:                "GET / ndjson streams through the hooks",
+			method:              "GET",
+			path:                "/",
+			accept:              "application/x-ndjson",
+			expectedCode:        200,
+			expectedContentType: "application/x-ndjson",
+			expectedResBody: `{"id":1,"name":"JOHN","email":"john@example.com","tags":["a","b"],"created":"2022-01-02T03:04:05Z"}` + "\n" +
+				`{"id":2,"name":"PAUL","email":null,"tags":null,"created":"2022-01-02T03:04:05Z"}`,
+		}, {
+			name:                "GET / csv paginated",
+			method:              "GET",
+			path:                "/?limit=1&offset=1",
+			accept:              "text/csv",
+			expectedCode:        200,
+			expectedContentType: "text/csv",
+			expectedResBody:     "id,full_name,email,tags,created,meta\n2,PAUL,,,2022-01-02T03:04:05Z,",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			var body io.Reader
+			if test.reqBody != "" {
+				body = strings.NewReader(test.reqBody)
+			}
+			r := httptest.NewRequest(test.method, test.path, body)
+			if test.contentType != "" {
+				r.Header.Set("Content-Type", test.contentType)
+			}
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			g.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := test.expectedContentType, w.Header().Get("Content-Type"); e != g {
+				t.Errorf("expected Content-Type %s, got %s", e, g)
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
+
+func TestStrictJSON(t *testing.T) {
+	g := ghost.Ghost[Item, SearchQuery, uint64]{
+		Server: ghost.NewServer[Item, SearchQuery, uint64](
+			ghost.NewMapStore(Item{}, SearchQuery{}, uint64(0)),
+			ghost.JSON[Item]{
+				MaxBytes:              32,
+				DisallowUnknownFields: true,
+				DisallowTrailingData:  true,
+				RequireContentType:    true,
+			},
+			ghost.PathIdentifier[uint64](ghost.UintPath[uint64]),
+			ghost.NewQueryParser[SearchQuery](),
+		),
+		Mux:          ghost.DefaultMux[Item, SearchQuery],
+		ErrorHandler: ghost.DefaultErrorHandler(ghost.JSON[ghost.Error]{}),
+	}
+
+	tests := []struct {
+		name, method, path, contentType, reqBody string
+		expectedCode                             int
+		expectedResBody                          string
+	}{
+		{
+			name:            "POST /",
+			method:          "POST",
+			path:            "/",
+			contentType:     "application/json; charset=utf-8",
+			reqBody:         `{"name":"John"}` + "\n",
+			expectedCode:    201,
+			expectedResBody: `{"id":1,"name":"John"}`,
+		}, {
+			name:            "POST / without Content-Type",
+			method:          "POST",
+			path:            "/",
+			reqBody:         `{"name":"John"}`,
+			expectedCode:    415,
+			expectedResBody: `{"error":"Unsupported Media Type"}`,
+		}, {
+			name:            "POST / with an unknown field",
+			method:          "POST",
+			path:            "/",
+			contentType:     "application/json",
+			reqBody:         `{"nmae":"John"}`,
+			expectedCode:    400,
+			expectedResBody: `{"error":"invalid request body: json: unknown field \"nmae\""}`,
+		}, {
+			name:            "POST / with trailing data",
+			method:          "POST",
+			path:            "/",
+			contentType:     "application/json",
+			reqBody:         `{"name":"John"} {}`,
+			expectedCode:    400,
+			expectedResBody: `{"error":"invalid request body: unexpected data after the JSON value"}`,
+		}, {
+			name:            "POST / with a large body",
+			method:          "POST",
+			path:            "/",
+			contentType:     "application/json",
+			reqBody:         `{"name":"` + strings.Repeat("a", 32) + `"}`,
+			expectedCode:    413,
+			expectedResBody: `{"error":"Request Entity Too Large"}`,
+		}, {
+			name:            "POST / with a body of exactly MaxBytes",
+			method:          "POST",
+			path:            "/",
+			contentType:     "application/json",
+			reqBody:         `{"name":"` + strings.Repeat("a", 21) + `"}`,
+			expectedCode:    201,
+			expectedResBody: `{"id":2,"name":"` + strings.Repeat("a", 21) + `"}`,
+		}, {
+			name:            "PATCH /1 with an unknown field",
+			method:          "PATCH",
+			path:            "/1",
+			contentType:     "application/merge-patch+json",
+			reqBody:         `{"nmae":"Bob"}`,
+			expectedCode:    400,
+			expectedResBody: `{"error":"invalid request body: json: unknown field \"nmae\""}`,
+		}, {
+			name:            "PATCH /1 with a large body",
+			method:          "PATCH",
+			path:            "/1",
+			contentType:     "application/merge-patch+json",
+			reqBody:         `{"name":"` + strings.Repeat("a", 32) + `"}`,
+			expectedCode:    413,
+			expectedResBody: `{"error":"Request Entity Too Large"}`,
+		}, {
+			name:            "PATCH /1 with trailing data",
+			method:          "PATCH",
+			path:            "/1",
+			contentType:     "application/merge-patch+json",
+			reqBody:         `{"name":"Bob"} {}`,
+			expectedCode:    400,
+			expectedResBody: `{"error":"invalid request body: unexpected data after the JSON value"}`,
+		}, {
+			name:            "PATCH /1 with a JSON patch with trailing data",
+			method:          "PATCH",
+			path:            "/1",
+			contentType:     "application/json-patch+json",
+			reqBody:         `[] []`,
+			expectedCode:    400,
+			expectedResBody: `{"error":"invalid request body: unexpected data after the JSON value"}`,
+		}, {
+			name:            "PATCH /1 with a large JSON patch",
+			method:          "PATCH",
+			path:            "/1",
+			contentType:     "application/json-patch+json",
+			reqBody:         `[{"op":"add","path":"/name","value":"Bob"}]`,
+			expectedCode:    413,
+			expectedResBody: `{"error":"Request Entity Too Large"}`,
+		}, {
+			name:            "PATCH /1 without Content-Type",
+			method:          "PATCH",
+			path:            "/1",
+			reqBody:         `{"name":"Bob"}`,
+			expectedCode:    415,
+			expectedResBody: `{"error":"Unsupported Media Type"}`,
+		}, {
+			name:            "PATCH /1",
+			method:          "PATCH",
+			path:            "/1",
+			contentType:     "application/merge-patch+json",
+			reqBody:         `{"name":"Bob"}`,
+			expectedCode:    200,
+			expectedResBody: `{"id":1,"name":"Bob"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.reqBody))
+			if test.contentType != "" {
+				r.Header.Set("Content-Type", test.contentType)
+			}
+			g.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
+
+func TestJSONPointer(t *testing.T) {
+	g := ghost.Ghost[Item, SearchQuery, uint64]{
+		Server: ghost.NewServer[Item, SearchQuery, uint64](
+			ghost.NewMapStore(Item{}, SearchQuery{}, uint64(0)),
+			&ghost.JSON[Item]{MaxBytes: 16},
+			ghost.PathIdentifier[uint64](ghost.UintPath[uint64]),
+			ghost.NewQueryParser[SearchQuery](),
+		),
+		Mux:          ghost.DefaultMux[Item, SearchQuery],
+		ErrorHandler: ghost.DefaultErrorHandler(ghost.JSON[ghost.Error]{}),
+	}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"John"}`)))
+	if e, g := 201, w.Code; e != g {
+		t.Fatalf("expected %d, got %d", e, g)
+	}
+
+	w = httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("PATCH", "/1", strings.NewReader(`{"name":"Bartholomew"}`)))
+	if e, g := 413, w.Code; e != g {
+		t.Errorf("expected %d, got %d", e, g)
+	}
+}
+
+type Memo struct {
+	Text  string `json:"text"`
+	Delta int    `json:"delta"`
+}
+
+func TestCSV(t *testing.T) {
+	memos := []Memo{{Text: "=SUM(A1:A2)", Delta: -1}, {Text: "@cmd"}, {Text: "-2+3"}, {Text: "\tx"}, {Text: "plain"}}
+
+	tests := []struct {
+		name     string
+		encoding ghost.Encoding[Memo]
+		expected string
+	}{
+		{
+			name:     "formulas are escaped",
+			encoding: ghost.CSV[Memo]{},
+			expected: "text,delta\n'=SUM(A1:A2),-1\n'@cmd,0\n'-2+3,0\n'\tx,0\nplain,0",
+		}, {
+			name:     "AllowFormulas",
+			encoding: ghost.CSV[Memo]{AllowFormulas: true},
+			expected: "text,delta\n=SUM(A1:A2),-1\n@cmd,0\n-2+3,0\n\"\tx\",0\nplain,0",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if err := test.encoding.EncodeList(w, memos, http.StatusOK); err != nil {
+				t.Fatal(err)
+			}
+			if e, g := test.expected, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %q, got %q", e, g)
+			}
+
+			// Decode reverses the escaping
+			for _, n := range memos {
+				w := httptest.NewRecorder()
+				if err := test.encoding.Encode(w, n, http.StatusOK); err != nil {
+					t.Fatal(err)
+				}
+				got, err := test.encoding.Decode(httptest.NewRequest("POST", "/", w.Body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if e, g := n, got; e != g {
+					t.Errorf("expected %v, got %v", e, g)
+				}
+			}
+		})
+	}
+
+	t.Run("pointers", func(t *testing.T) {
+		enc := ghost.CSV[*Memo]{}
+		w := httptest.NewRecorder()
+		if err := enc.EncodeList(w, []*Memo{{Text: "a", Delta: 1}, nil}, http.StatusOK); err != nil {
+			t.Fatal(err)
+		}
+		if e, g := "text,delta\na,1\n,", strings.TrimSpace(w.Body.String()); e != g {
+			t.Fatalf("expected %q, got %q", e, g)
+		}
+		got, err := enc.Decode(httptest.NewRequest("POST", "/", strings.NewReader("text,delta\n'=b,2\n")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e, g := (Memo{Text: "=b", Delta: 2}), *got; e != g {
+			t.Errorf("expected %v, got %v", e, g)
+		}
+	})
+}
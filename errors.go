@@ -4,19 +4,64 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strings"
 )
 
 type Error struct {
 	Code int   `json:"-"`
 	Err  error `json:"error"`
+	// Type is a URI which identifies the type of the problem, see ProblemErrorHandler.
+	Type string `json:"-"`
+}
+
+// Extender is implemented by the Err of Errors which have additional members,
+// which the JSON encoding and the problem details include, see ProblemErrorHandler.
+type Extender interface {
+	Extensions() map[string]any
 }
 
 func (e Error) Error() string {
 	return fmt.Sprintf("code=%d, err=%s", e.Code, e.Err.Error())
 }
 
+// Is reports whether target is an Error with the same Code, Type and Err,
+// so that errors.Is matches the Err variables with Errors which wrap their Err.
+func (e Error) Is(target error) bool {
+	t, ok := target.(Error)
+	return ok && e.Code == t.Code && e.Type == t.Type && errors.Is(e.Err, t.Err)
+}
+
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+// extensions returns the Extensions of the Err, if it is an Extender.
+func (e Error) extensions() map[string]any {
+	var x Extender
+	if errors.As(e.Err, &x) {
+		return x.Extensions()
+	}
+	return nil
+}
+
+// Members returns the members which e is encoded with: the extensions of its Err, and its message as "error".
+// Encodings which encode Errors as maps use it, so that they encode the same members as JSON.
+func (e Error) Members() map[string]any {
+	ext := e.extensions()
+	m := make(map[string]any, len(ext)+1)
+	for k, v := range ext {
+		m[k] = v
+	}
+	m["error"] = e.Err.Error()
+	return m
+}
+
 func (e Error) MarshalJSON() ([]byte, error) {
+	if len(e.extensions()) > 0 {
+		return json.Marshal(e.Members())
+	}
 	b := []byte(`{"error":`)
 	be, err := json.Marshal(e.Err.Error())
 	if err != nil {
@@ -37,9 +82,49 @@ var ErrMethodNotAllowed = Error{
 	Err:  errors.New(http.StatusText(http.StatusMethodNotAllowed)),
 }
 
+var ErrUnsupportedMediaType = Error{
+	Code: http.StatusUnsupportedMediaType,
+	Err:  errors.New(http.StatusText(http.StatusUnsupportedMediaType)),
+}
+
+var ErrPreconditionFailed = Error{
+	Code: http.StatusPreconditionFailed,
+	Err:  errors.New(http.StatusText(http.StatusPreconditionFailed)),
+}
+
+var ErrRequestEntityTooLarge = Error{
+	Code: http.StatusRequestEntityTooLarge,
+	Err:  errors.New(http.StatusText(http.StatusRequestEntityTooLarge)),
+}
+
+var ErrInternalServerError = Error{
+	Code: http.StatusInternalServerError,
+	Err:  errors.New(http.StatusText(http.StatusInternalServerError)),
+}
+
+// ErrNotImplemented is returned by stores which do not support an optional operation.
+// Store wrappers return it when the wrapped store does not support the operation, so that callers can fall back.
+var ErrNotImplemented = Error{
+	Code: http.StatusNotImplemented,
+	Err:  errors.New(http.StatusText(http.StatusNotImplemented)),
+}
+
 func DefaultErrorHandler(encoding Encoding[Error]) func(err error) http.Handler {
+	return NegotiatingErrorHandler(Encodings[Error]{encoding})
+}
+
+// NegotiatingErrorHandler encodes errors with the Encoding negotiated among encodings,
+// or the default Encoding if none is acceptable.
+// If encodings is empty, it responds with a plain text 500 Internal Server Error.
+func NegotiatingErrorHandler(encodings Encodings[Error]) func(err error) http.Handler {
 	return func(err error) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// on failure, encoding is the default
+			encoding, _ := encodings.Response(r)
+			if encoding == nil {
+				noEncodings(w)
+				return
+			}
 			var e Error
 			if errors.As(err, &e) {
 				_ = encoding.Encode(w, e, e.Code)
@@ -49,3 +134,103 @@ func DefaultErrorHandler(encoding Encoding[Error]) func(err error) http.Handler
 		})
 	}
 }
+
+// noEncodings responds to a request which the error handler has no Encodings to respond to.
+func noEncodings(w http.ResponseWriter) {
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// BodyError is the Err of the 400 Bad Request Error returned when the request body can't be decoded.
+type BodyError struct {
+	Err error
+}
+
+func (e BodyError) Error() string {
+	return "invalid request body: " + e.Err.Error()
+}
+
+func (e BodyError) Unwrap() error {
+	return e.Err
+}
+
+// bodyError wraps err of decoding the request body in a 400 Bad Request Error, unless it already is an Error.
+func bodyError(err error) error {
+	var e Error
+	if errors.As(err, &e) {
+		return err
+	}
+	return Error{Code: http.StatusBadRequest, Err: BodyError{Err: err}}
+}
+
+// StreamError is the error of a streamed list which failed after the response was partly written,
+// so that it can't be replaced by an error response.
+type StreamError struct {
+	Err error
+}
+
+func (e StreamError) Error() string {
+	return "streaming: " + e.Err.Error()
+}
+
+func (e StreamError) Unwrap() error {
+	return e.Err
+}
+
+// PathKeyError is the Err of the 404 Not Found Error returned when the PKey can't be parsed from the request path.
+type PathKeyError struct {
+	Key string
+	Err error
+}
+
+func (e PathKeyError) Error() string {
+	return fmt.Sprintf("invalid key %q: %v", e.Key, e.Err)
+}
+
+func (e PathKeyError) Unwrap() error {
+	return e.Err
+}
+
+// QueryParamError is an error parsing the URL query parameter Param.
+type QueryParamError struct {
+	Param string
+	Err   error
+}
+
+func (e QueryParamError) Error() string {
+	return fmt.Sprintf("invalid query parameter %q: %v", e.Param, e.Err)
+}
+
+func (e QueryParamError) Unwrap() error {
+	return e.Err
+}
+
+// QueryParamErrors is the Err of the 400 Bad Request Error returned when the URL query parameters can't be parsed.
+// They are sorted by Param.
+type QueryParamErrors []QueryParamError
+
+func (e QueryParamErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, qe := range e {
+		messages[i] = qe.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ProductionErrorHandler wraps handler so that errors which aren't Errors are logged to logger,
+// or the standard logger if it is nil, and handled as ErrInternalServerError.
+// Their messages may expose internals, such as SQL statements, which clients shouldn't see.
+func ProductionErrorHandler(handler func(error) http.Handler, logger *log.Logger) func(err error) http.Handler {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return func(err error) http.Handler {
+		var e Error
+		if errors.As(err, &e) {
+			return handler(err)
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+			handler(ErrInternalServerError).ServeHTTP(w, r)
+		})
+	}
+}
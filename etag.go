@@ -0,0 +1,126 @@
+package ghost
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// versionETag returns the strong ETag of a version in the representation of mediaType.
+// Without a mediaType, the ETag is the quoted version.
+func versionETag(version, mediaType string) string {
+	if mediaType == "" {
+		return `"` + version + `"`
+	}
+	return `"` + version + ";" + mediaType + `"`
+}
+
+// bodyETag returns the strong ETag of an encoded body.
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// noneMatch reports whether the If-None-Match header of r doesn't match etag, using the weak comparison.
+// It reports true if there is no If-None-Match header.
+func noneMatch(r *http.Request, etag string) bool {
+	for _, h := range r.Header.Values("If-None-Match") {
+		for _, tag := range strings.Split(h, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ifMatch returns the entity tags of the If-Match header of r, and whether it has one.
+func ifMatch(r *http.Request) ([]string, bool) {
+	values := r.Header.Values("If-Match")
+	var tags []string
+	for _, h := range values {
+		for _, tag := range strings.Split(h, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags, len(values) > 0
+}
+
+// match reports whether any of tags matches etag, using the strong comparison.
+// Weak entity tags never match.
+func match(tags []string, etag string) bool {
+	if strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	for _, tag := range tags {
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified responds with 304 Not Modified.
+func notModified(w http.ResponseWriter, etag string) error {
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusNotModified)
+	return nil
+}
+
+// bufferedResponse is a http.ResponseWriter which buffers the response,
+// so that the body can be hashed before the header is written.
+// Without w, it only buffers.
+type bufferedResponse struct {
+	w      http.ResponseWriter
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	if b.w != nil {
+		return b.w.Header()
+	}
+	if b.header == nil {
+		b.header = http.Header{}
+	}
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(code int) {
+	if b.code == 0 {
+		b.code = code
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.code == 0 {
+		b.code = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+// respond writes the buffered response, or 304 Not Modified if the If-None-Match header of r matches.
+// The ETag is etag if not empty, or the hash of the body.
+func (b *bufferedResponse) respond(r *http.Request, etag string) error {
+	if b.code == 0 {
+		b.code = http.StatusOK
+	}
+	if etag == "" {
+		etag = bodyETag(b.body.Bytes())
+	}
+	if !noneMatch(r, etag) {
+		// the 304 response doesn't have the representation headers
+		b.w.Header().Del("Content-Type")
+		return notModified(b.w, etag)
+	}
+	b.w.Header().Set("ETag", etag)
+	b.w.WriteHeader(b.code)
+	_, err := b.body.WriteTo(b.w)
+	return err
+}
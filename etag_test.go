@@ -0,0 +1,341 @@
+package ghost_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mash/ghost"
+)
+
+type Document struct {
+	Body     string `json:"body"`
+	Revision int    `json:"revision"`
+}
+
+func (d *Document) Version() string {
+	return strconv.Itoa(d.Revision)
+}
+
+func TestETag(t *testing.T) {
+	do := func(h http.Handler, method, path, ifNoneMatch, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		var b io.Reader
+		if body != "" {
+			b = strings.NewReader(body)
+		}
+		r := httptest.NewRequest(method, path, b)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	expect := func(w *httptest.ResponseRecorder, code int, body string) {
+		t.Helper()
+		if e, g := code, w.Code; e != g {
+			t.Errorf("expected %d, got %d", e, g)
+		}
+		if e, g := body, strings.TrimSpace(w.Body.String()); e != g {
+			t.Errorf("expected %s, got %s", e, g)
+		}
+	}
+
+	t.Run("hash", func(t *testing.T) {
+		g := ghost.New(ghost.NewMapStore(User{}, SearchQuery{}, uint64(0)))
+		do(g, "POST", "/", "", `{"Name":"John"}`)
+
+		w := do(g, "GET", "/1", "", "")
+		expect(w, 200, `{"Name":"John"}`)
+		etag := w.Header().Get("ETag")
+		if !strings.HasPrefix(etag, `"`) {
+			t.Fatalf("expected a strong ETag, got %s", etag)
+		}
+		w = do(g, "GET", "/1", etag, "")
+		expect(w, 304, ``)
+		if e, g := etag, w.Header().Get("ETag"); e != g {
+			t.Errorf("expected ETag %s, got %s", e, g)
+		}
+		// the weak comparison applies
+		expect(do(g, "GET", "/1", `"other", W/`+etag, ""), 304, ``)
+
+		w = do(g, "GET", "/", "", "")
+		expect(w, 200, `[{"Name":"John"}]`)
+		listETag := w.Header().Get("ETag")
+		expect(do(g, "GET", "/", listETag, ""), 304, ``)
+
+		do(g, "PUT", "/1", "", `{"Name":"Bob"}`)
+		w = do(g, "GET", "/1", etag, "")
+		expect(w, 200, `{"Name":"Bob"}`)
+		if w.Header().Get("ETag") == etag {
+			t.Errorf("expected the ETag to change")
+		}
+		expect(do(g, "GET", "/", listETag, ""), 200, `[{"Name":"Bob"}]`)
+	})
+
+	t.Run("version", func(t *testing.T) {
+		g := ghost.New(ghost.NewMapStore(Document{}, SearchQuery{}, uint64(0)))
+		do(g, "POST", "/", "", `{"body":"a","revision":1}`)
+
+		w := do(g, "GET", "/1", "", "")
+		expect(w, 200, `{"body":"a","revision":1}`)
+		if e, g := `"1"`, w.Header().Get("ETag"); e != g {
+			t.Errorf("expected ETag %s, got %s", e, g)
+		}
+		expect(do(g, "GET", "/1", `"1"`, ""), 304, ``)
+		expect(do(g, "GET", "/1", `W/"1"`, ""), 304, ``)
+		expect(do(g, "GET", "/1", `*`, ""), 304, ``)
+		expect(do(g, "GET", "/1", `W/"0"`, ""), 200, `{"body":"a","revision":1}`)
+	})
+}
+
+type Note struct {
+	Text     string `json:"text"`
+	Revision uint64 `json:"version"`
+}
+
+func (n *Note) Version() string {
+	return strconv.FormatUint(n.Revision, 10)
+}
+
+func (n *Note) SetVersion(v uint64) {
+	n.Revision = v
+}
+
+// racyStore writes the resource after the first Read, like a concurrent request would.
+type racyStore struct {
+	ghost.Store[Note, SearchQuery, uint64]
+	raced bool
+}
+
+func (s *racyStore) Read(ctx context.Context, pkey uint64, q *SearchQuery) (*Note, error) {
+	n, err := s.Store.Read(ctx, pkey, q)
+	if err == nil && !s.raced {
+		s.raced = true
+		err = s.Store.Update(ctx, pkey, &Note{Text: "concurrent"})
+	}
+	return n, err
+}
+
+func (s *racyStore) UpdateIf(ctx context.Context, pkey uint64, n *Note, version string) error {
+	return s.Store.(ghost.ConditionalStore[Note, uint64]).UpdateIf(ctx, pkey, n, version)
+}
+
+func (s *racyStore) DeleteIf(ctx context.Context, pkey uint64, version string) error {
+	return s.Store.(ghost.ConditionalStore[Note, uint64]).DeleteIf(ctx, pkey, version)
+}
+
+func TestPatchConcurrentWrite(t *testing.T) {
+	for _, contentType := range []string{ghost.MergePatchType, ghost.JSONPatchType} {
+		t.Run(contentType, func(t *testing.T) {
+			store := ghost.NewMapStore(Note{}, SearchQuery{}, uint64(0))
+			if err := store.Create(context.Background(), &Note{Text: "a"}); err != nil {
+				t.Fatal(err)
+			}
+			g := ghost.New[Note, SearchQuery, uint64](&racyStore{Store: store})
+
+			body := `{"text":"b"}`
+			if contentType == ghost.JSONPatchType {
+				body = `[{"op":"replace","path":"/text","value":"b"}]`
+			}
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("PATCH", "/1", strings.NewReader(body))
+			r.Header.Set("Content-Type", contentType)
+			g.ServeHTTP(w, r)
+
+			if e, g := 412, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			n, err := store.Read(context.Background(), 1, &SearchQuery{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e, g := "concurrent", n.Text; e != g {
+				t.Errorf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	do := func(h http.Handler, method, path, ifMatch, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		var b io.Reader
+		if body != "" {
+			b = strings.NewReader(body)
+		}
+		r := httptest.NewRequest(method, path, b)
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("version", func(t *testing.T) {
+		g := ghost.New(ghost.NewMapStore(Note{}, SearchQuery{}, uint64(0)))
+
+		tests := []struct {
+			name, method, path, ifMatch, reqBody string
+			expectedCode                         int
+			expectedETag                         string
+			expectedResBody                      string
+		}{
+			{
+				name:            "POST /",
+				method:          "POST",
+				path:            "/",
+				reqBody:         `{"text":"a","version":5}`,
+				expectedCode:    201,
+				expectedResBody: `{"text":"a","version":1}`,
+			}, {
+				name:            "PUT /1 matching",
+				method:          "PUT",
+				path:            "/1",
+				ifMatch:         `"1"`,
+				reqBody:         `{"text":"b"}`,
+				expectedCode:    200,
+				expectedETag:    `"2"`,
+				expectedResBody: `{"text":"b","version":2}`,
+			}, {
+				name:            "PUT /1 with a stale version",
+				method:          "PUT",
+				path:            "/1",
+				ifMatch:         `"1"`,
+				reqBody:         `{"text":"c"}`,
+				expectedCode:    412,
+				expectedResBody: `{"error":"Precondition Failed"}`,
+			}, {
+				name:            "PUT /1 with a weak entity tag",
+				method:          "PUT",
+				path:            "/1",
+				ifMatch:         `W/"2"`,
+				reqBody:         `{"text":"c"}`,
+				expectedCode:    412,
+				expectedResBody: `{"error":"Precondition Failed"}`,
+			}, {
+				name:            "PUT /1 without If-Match",
+				method:          "PUT",
+				path:            "/1",
+				reqBody:         `{"text":"c"}`,
+				expectedCode:    200,
+				expectedETag:    `"3"`,
+				expectedResBody: `{"text":"c","version":3}`,
+			}, {
+				name:            "PATCH /1 with a stale version",
+				method:          "PATCH",
+				path:            "/1",
+				ifMatch:         `"2"`,
+				reqBody:         `{"text":"d"}`,
+				expectedCode:    412,
+				expectedResBody: `{"error":"Precondition Failed"}`,
+			}, {
+				name:            "PATCH /1 matching",
+				method:          "PATCH",
+				path:            "/1",
+				ifMatch:         `"3"`,
+				reqBody:         `{"text":"d"}`,
+				expectedCode:    200,
+				expectedETag:    `"4"`,
+				expectedResBody: `{"text":"d","version":4}`,
+			}, {
+				name:            "DELETE /1 with a stale version",
+				method:          "DELETE",
+				path:            "/1",
+				ifMatch:         `"3"`,
+				expectedCode:    412,
+				expectedResBody: `{"error":"Precondition Failed"}`,
+			}, {
+				name:         "DELETE /1 matching",
+				method:       "DELETE",
+				path:         "/1",
+				ifMatch:      `"4"`,
+				expectedCode: 204,
+			}, {
+				name:            "PUT /1 deleted",
+				method:          "PUT",
+				path:            "/1",
+				ifMatch:         `"4"`,
+				reqBody:         `{"text":"e"}`,
+				expectedCode:    404,
+				expectedResBody: `{"error":"Not Found"}`,
+			},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				w := do(g, test.method, test.path, test.ifMatch, test.reqBody)
+				if e, g := test.expectedCode, w.Code; e != g {
+					t.Errorf("expected %d, got %d", e, g)
+				}
+				if e, g := test.expectedETag, w.Header().Get("ETag"); e != g {
+					t.Errorf("expected ETag %s, got %s", e, g)
+				}
+				if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+					t.Fatalf("expected %s, got %s", e, g)
+				}
+			})
+		}
+	})
+
+	t.Run("representations", func(t *testing.T) {
+		store := ghost.NewMapStore(Note{}, SearchQuery{}, uint64(0))
+		g := ghost.Ghost[Note, SearchQuery, uint64]{
+			Server: ghost.NewNegotiatingServer[Note, SearchQuery, uint64](
+				store,
+				ghost.Encodings[Note]{ghost.JSON[Note]{}, ghost.CSV[Note]{}},
+				ghost.PathIdentifier[uint64](ghost.UintPath[uint64]),
+				ghost.NewQueryParser[SearchQuery](),
+			),
+			Mux:          ghost.DefaultMux[Note, SearchQuery],
+			ErrorHandler: ghost.DefaultErrorHandler(ghost.JSON[ghost.Error]{}),
+		}
+		do(g, "POST", "/", "", `{"text":"a"}`)
+
+		get := func(accept string) string {
+			r := httptest.NewRequest("GET", "/1", nil)
+			r.Header.Set("Accept", accept)
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, r)
+			return w.Header().Get("ETag")
+		}
+		if e, g := `"1;application/json"`, get("application/json"); e != g {
+			t.Errorf("expected ETag %s, got %s", e, g)
+		}
+		csvETag := get("text/csv")
+		if e, g := `"1;text/csv"`, csvETag; e != g {
+			t.Errorf("expected ETag %s, got %s", e, g)
+		}
+		// the version matches in any representation
+		if e, g := 200, do(g, "PUT", "/1", csvETag, `{"text":"b"}`).Code; e != g {
+			t.Errorf("expected %d, got %d", e, g)
+		}
+		if e, g := 412, do(g, "PUT", "/1", csvETag, `{"text":"c"}`).Code; e != g {
+			t.Errorf("expected %d, got %d", e, g)
+		}
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		g := ghost.New(ghost.NewMapStore(User{}, SearchQuery{}, uint64(0)))
+		do(g, "POST", "/", "", `{"Name":"John"}`)
+		etag := do(g, "GET", "/1", "", "").Header().Get("ETag")
+
+		if e, g := 200, do(g, "PUT", "/1", etag, `{"Name":"Bob"}`).Code; e != g {
+			t.Errorf("expected %d, got %d", e, g)
+		}
+		if e, g := 412, do(g, "PUT", "/1", etag, `{"Name":"Alice"}`).Code; e != g {
+			t.Errorf("expected %d, got %d", e, g)
+		}
+		if e, g := 200, do(g, "PUT", "/1", "*", `{"Name":"Alice"}`).Code; e != g {
+			t.Errorf("expected %d, got %d", e, g)
+		}
+		if e, g := 404, do(g, "DELETE", "/2", "*", ``).Code; e != g {
+			t.Errorf("expected %d, got %d", e, g)
+		}
+	})
+}
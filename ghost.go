@@ -1,6 +1,9 @@
 package ghost
 
-import "net/http"
+import (
+	"errors"
+	"net/http"
+)
 
 type Ghost[R Resource, Q Query, P PKey] struct {
 	Server       Server
@@ -30,8 +33,21 @@ func NewS[R Resource, Q Query, P PStrKey](store Store[R, Q, P]) http.Handler {
 	}
 }
 
+// ServeHTTP handles errors with the ErrorHandler.
+// A StreamError can't replace the partly written response, so the ErrorHandler only gets to log it,
+// and the response is aborted with http.ErrAbortHandler instead, which lets the client tell it is incomplete.
 func (g Ghost[R, Q, P]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err := g.Mux(g.Server)(w, r); err != nil {
+		var se StreamError
+		if errors.As(err, &se) {
+			g.ErrorHandler(err).ServeHTTP(&bufferedResponse{}, r)
+			panic(http.ErrAbortHandler)
+		}
 		g.ErrorHandler(err).ServeHTTP(w, r)
 	}
 }
+
+// Operations returns the operations of the Server, see Operator.
+func (g Ghost[R, Q, P]) Operations() []Operation {
+	return operations(g.Server)
+}
@@ -0,0 +1,187 @@
+package ghost_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mash/ghost"
+)
+
+// readOnlyStore supports reading and listing only.
+type readOnlyStore struct {
+	ghost.Store[User, SearchQuery, uint64]
+}
+
+func (s readOnlyStore) Operations() []ghost.Operation {
+	return []ghost.Operation{ghost.OperationRead, ghost.OperationList}
+}
+
+func TestHeadOptions(t *testing.T) {
+	store := ghost.NewMapStore(User{}, SearchQuery{}, uint64(0))
+	g := ghost.New(store)
+	readOnly := ghost.New[User, SearchQuery, uint64](readOnlyStore{store})
+
+	tests := []struct {
+		name, method, path, reqBody string
+		handler                     http.Handler
+		expectedCode                int
+		expectedHeader              map[string]string
+		expectedResBody             string
+	}{
+		{
+			name:            "POST /",
+			handler:         g,
+			method:          "POST",
+			path:            "/",
+			reqBody:         `{"Name":"John"}`,
+			expectedCode:    201,
+			expectedResBody: `{"Name":"John"}`,
+		}, {
+			name:            "POST / again",
+			handler:         g,
+			method:          "POST",
+			path:            "/",
+			reqBody:         `{"Name":"Bob"}`,
+			expectedCode:    201,
+			expectedResBody: `{"Name":"Bob"}`,
+		}, {
+			name:            "GET /",
+			handler:         g,
+			method:          "GET",
+			path:            "/",
+			expectedCode:    200,
+			expectedResBody: `[{"Name":"John"},{"Name":"Bob"}]`,
+			expectedHeader: map[string]string{
+				"Content-Type":  "application/json",
+				"ETag":          `"bc8481897c12b37d2d7e944a005dc58a"`,
+				"X-Total-Count": "2",
+			},
+		}, {
+			name:         "HEAD /",
+			handler:      g,
+			method:       "HEAD",
+			path:         "/",
+			expectedCode: 200,
+			expectedHeader: map[string]string{
+				"Content-Type":  "application/json",
+				"ETag":          `"bc8481897c12b37d2d7e944a005dc58a"`,
+				"X-Total-Count": "2",
+			},
+		}, {
+			name:         "HEAD /?limit=1",
+			handler:      g,
+			method:       "HEAD",
+			path:         "/?limit=1",
+			expectedCode: 200,
+			expectedHeader: map[string]string{
+				"Link":          `</?cursor=MQ&limit=1>; rel="next"`,
+				"X-Total-Count": "2",
+			},
+		}, {
+			name:         "HEAD /?name=John",
+			handler:      g,
+			method:       "HEAD",
+			path:         "/?name=John",
+			expectedCode: 200,
+			expectedHeader: map[string]string{
+				"X-Total-Count": "1",
+			},
+		}, {
+			name:         "HEAD /1",
+			handler:      g,
+			method:       "HEAD",
+			path:         "/1",
+			expectedCode: 200,
+			expectedHeader: map[string]string{
+				"Content-Type": "application/json",
+				"ETag":         `"20d51eb9a25fd931e3190c68d7aa746a"`,
+			},
+		}, {
+			name:            "HEAD /3",
+			handler:         g,
+			method:          "HEAD",
+			path:            "/3",
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		}, {
+			name:         "OPTIONS /",
+			handler:      g,
+			method:       "OPTIONS",
+			path:         "/",
+			expectedCode: 204,
+			expectedHeader: map[string]string{
+				"Allow": "GET, HEAD, OPTIONS, POST",
+			},
+		}, {
+			name:         "OPTIONS /1",
+			handler:      g,
+			method:       "OPTIONS",
+			path:         "/1",
+			expectedCode: 204,
+			expectedHeader: map[string]string{
+				"Allow": "DELETE, GET, HEAD, OPTIONS, PATCH, PUT",
+			},
+		}, {
+			name:            "TRACE /1",
+			handler:         g,
+			method:          "TRACE",
+			path:            "/1",
+			expectedCode:    405,
+			expectedResBody: `{"error":"Method Not Allowed"}`,
+			expectedHeader: map[string]string{
+				"Allow": "DELETE, GET, HEAD, OPTIONS, PATCH, PUT",
+			},
+		}, {
+			name:         "OPTIONS / read only",
+			handler:      readOnly,
+			method:       "OPTIONS",
+			path:         "/",
+			expectedCode: 204,
+			expectedHeader: map[string]string{
+				"Allow": "GET, HEAD, OPTIONS",
+			},
+		}, {
+			name:            "POST / read only",
+			handler:         readOnly,
+			method:          "POST",
+			path:            "/",
+			reqBody:         `{"Name":"Alice"}`,
+			expectedCode:    405,
+			expectedResBody: `{"error":"Method Not Allowed"}`,
+			expectedHeader: map[string]string{
+				"Allow": "GET, HEAD, OPTIONS",
+			},
+		}, {
+			name:            "DELETE /1 read only",
+			handler:         readOnly,
+			method:          "DELETE",
+			path:            "/1",
+			expectedCode:    405,
+			expectedResBody: `{"error":"Method Not Allowed"}`,
+			expectedHeader: map[string]string{
+				"Allow": "GET, HEAD, OPTIONS",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.reqBody))
+			test.handler.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			for k, e := range test.expectedHeader {
+				if g := w.Header().Get(k); e != g {
+					t.Errorf("expected %s %s, got %s", k, e, g)
+				}
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
@@ -3,27 +3,115 @@ package ghost
 import (
 	"net/http"
 	"path"
+	"sort"
+	"strings"
 )
 
 type Handler = func(http.ResponseWriter, *http.Request) error
 
+// HeadServer is implemented by Servers which respond to HEAD requests other than like GET without the body.
+type HeadServer interface {
+	Head(http.ResponseWriter, *http.Request) error
+}
+
+// DefaultMux routes requests to paths ending with a slash to the collection, and others to a resource.
+// HEAD is handled by HeadServers, or like GET without the body, and OPTIONS responds with the Allow header.
+// Methods of operations which the Server doesn't support, see Operator, are 405 Method Not Allowed with the Allow header.
+// So are methods which don't apply to the path, such as POST /1 and PUT /, which earlier versions passed to the Server.
 func DefaultMux[R Resource, Q Query](s Server) Handler {
 	return func(w http.ResponseWriter, r *http.Request) error {
+		_, f := path.Split(r.URL.Path)
+		collection := f == ""
+		allowed := allow(operations(s), collection)
+		if !contains(allowed, r.Method) {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			return ErrMethodNotAllowed
+		}
 		switch r.Method {
+		case http.MethodOptions:
+			return options(allowed)(w, r)
+		case http.MethodHead:
+			if h, ok := s.(HeadServer); ok {
+				return h.Head(w, r)
+			}
+			w = headResponse{w}
 		case http.MethodPost:
 			return s.Create(w, r)
 		case http.MethodPut:
 			return s.Update(w, r)
+		case http.MethodPatch:
+			return s.Patch(w, r)
 		case http.MethodDelete:
 			return s.Delete(w, r)
-		case http.MethodGet:
-			_, f := path.Split(r.URL.Path)
-			if f == "" {
-				return s.List(w, r)
-			}
-			return s.Read(w, r)
-		default:
-			return ErrMethodNotAllowed
 		}
+		if collection {
+			return s.List(w, r)
+		}
+		return s.Read(w, r)
 	}
 }
+
+// operations returns the operations of s if it is an Operator, or AllOperations.
+func operations(s Server) []Operation {
+	if o, ok := s.(Operator); ok {
+		return o.Operations()
+	}
+	return AllOperations
+}
+
+// allow returns the sorted methods allowed for the collection, or a resource, with the operations.
+func allow(operations []Operation, collection bool) []string {
+	methods := []string{http.MethodOptions}
+	for _, op := range operations {
+		switch {
+		case op == OperationList && collection, op == OperationRead && !collection:
+			methods = append(methods, http.MethodGet, http.MethodHead)
+		case op == OperationCreate && collection:
+			methods = append(methods, http.MethodPost)
+		case op == OperationUpdate && !collection:
+			methods = append(methods, http.MethodPut)
+		case op == OperationPatch && !collection:
+			methods = append(methods, http.MethodPatch)
+		case op == OperationDelete && !collection:
+			methods = append(methods, http.MethodDelete)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// options responds with the Allow header of the methods.
+func options(methods []string) Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
+
+func containsOperation(ops []Operation, op Operation) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// headResponse is a http.ResponseWriter which discards the body, for HEAD requests.
+type headResponse struct {
+	http.ResponseWriter
+}
+
+func (h headResponse) Write(p []byte) (int, error) {
+	return len(p), nil
+}
@@ -2,16 +2,18 @@ package ghost_test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/google/go-cmp/cmp"
 	"github.com/mash/ghost"
-	v "github.com/mash/ghost/store/validator"
 )
 
 type User struct {
@@ -61,21 +63,34 @@ func testHandler(t *testing.T, h http.Handler) {
 			reqBody:         `{"Name":"Bob"}`,
 			expectedCode:    200,
 			expectedResBody: `{"Name":"Bob"}`,
+		}, {
+			name:            "PATCH /1",
+			method:          "PATCH",
+			path:            "/1",
+			reqBody:         `{"Name":"Alice"}`,
+			expectedCode:    200,
+			expectedResBody: `{"Name":"Alice"}`,
 		}, {
 			name:            "GET /",
 			method:          "GET",
 			path:            "/",
 			expectedCode:    200,
-			expectedResBody: `[{"Name":"Bob"}]`,
+			expectedResBody: `[{"Name":"Alice"}]`,
 		}, {
 			name:            "DELETE /1",
 			method:          "DELETE",
 			path:            "/1",
 			expectedCode:    204,
-			expectedResBody: `{}`,
+			expectedResBody: ``,
 		}, {
-			name:            "PATCH /1",
-			method:          "PATCH",
+			name:            "DELETE /1 again",
+			method:          "DELETE",
+			path:            "/1",
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		}, {
+			name:            "TRACE /1",
+			method:          "TRACE",
 			path:            "/1",
 			expectedCode:    405,
 			expectedResBody: `{"error":"Method Not Allowed"}`,
@@ -101,6 +116,726 @@ func testHandler(t *testing.T, h http.Handler) {
 	}
 }
 
+type Profile struct {
+	Name   string
+	Email  *string
+	Tags   map[string]string
+	Secret string `json:"-"`
+}
+
+// patchStore is a Patcher which writes the fields of the patched resource onto the stored one.
+type patchStore struct {
+	ghost.Store[Profile, SearchQuery, uint64]
+	fields []string
+}
+
+func (s *patchStore) Patch(ctx context.Context, pkey uint64, r *Profile, fields []string) error {
+	cur, err := s.Read(ctx, pkey, &SearchQuery{})
+	if err != nil {
+		return err
+	}
+	s.fields = fields
+	src, dst := reflect.ValueOf(r).Elem(), reflect.ValueOf(cur).Elem()
+	for _, f := range fields {
+		dst.FieldByName(f).Set(src.FieldByName(f))
+	}
+	return s.Update(ctx, pkey, cur)
+}
+
+func TestPatch(t *testing.T) {
+	t.Run("map", func(t *testing.T) {
+		testPatch(t, ghost.NewMapStore(Profile{}, SearchQuery{}, uint64(0)))
+	})
+	t.Run("patcher", func(t *testing.T) {
+		store := &patchStore{Store: ghost.NewMapStore(Profile{}, SearchQuery{}, uint64(0))}
+		testPatch(t, store)
+		sort.Strings(store.fields)
+		if diff := cmp.Diff([]string{"Email", "Tags"}, store.fields); diff != "" {
+			t.Errorf("unexpected fields (-expected +got):\n%s", diff)
+		}
+	})
+}
+
+func testPatch(t *testing.T, store ghost.Store[Profile, SearchQuery, uint64]) {
+	email := "john@example.com"
+	if err := store.Create(context.Background(), &Profile{Name: "John", Email: &email, Tags: map[string]string{"a": "1", "b": "2"}, Secret: "s"}); err != nil {
+		t.Fatal(err)
+	}
+	g := ghost.New(store)
+
+	tests := []struct {
+		name, method, path, contentType, reqBody string
+		expectedCode                             int
+		expectedResBody                          string
+	}{
+		{
+			name:            "PATCH /1",
+			method:          "PATCH",
+			path:            "/1",
+			contentType:     "application/merge-patch+json",
+			reqBody:         `{"Email":null,"Tags":{"a":null,"c":"3"}}`,
+			expectedCode:    200,
+			expectedResBody: `{"Name":"John","Email":null,"Tags":{"b":"2","c":"3"}}`,
+		}, {
+			name:            "GET /1",
+			method:          "GET",
+			path:            "/1",
+			expectedCode:    200,
+			expectedResBody: `{"Name":"John","Email":null,"Tags":{"b":"2","c":"3"}}`,
+		}, {
+			name:            "PATCH /1 with a malformed body",
+			method:          "PATCH",
+			path:            "/1",
+			reqBody:         `{"Name":`,
+			expectedCode:    400,
+			expectedResBody: `{"error":"invalid request body: unexpected EOF"}`,
+		}, {
+			name:            "PATCH /1 with an unsupported media type",
+			method:          "PATCH",
+			path:            "/1",
+			contentType:     "text/plain",
+			reqBody:         `Name=Bob`,
+			expectedCode:    415,
+			expectedResBody: `{"error":"Unsupported Media Type"}`,
+		}, {
+			name:            "PATCH /2",
+			method:          "PATCH",
+			path:            "/2",
+			reqBody:         `{"Name":"Bob"}`,
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			var body io.Reader
+			if test.method != "GET" {
+				body = strings.NewReader(test.reqBody)
+			}
+			r := httptest.NewRequest(test.method, test.path, body)
+			if test.contentType != "" {
+				r.Header.Set("Content-Type", test.contentType)
+			}
+			g.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+
+	// fields which encoding/json ignores are kept
+	p, err := store.Read(context.Background(), 1, &SearchQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, g := "s", p.Secret; e != g {
+		t.Errorf("expected Secret %s, got %s", e, g)
+	}
+}
+
+type Ledger struct {
+	Balance uint64 `json:"balance"`
+	Dash    string `json:"-,"`
+	Note    string `json:"note"`
+}
+
+func TestPatchJSONValues(t *testing.T) {
+	store := ghost.NewMapStore(Ledger{}, SearchQuery{}, uint64(0))
+	if err := store.Create(context.Background(), &Ledger{Balance: 1<<63 + 1, Dash: "d"}); err != nil {
+		t.Fatal(err)
+	}
+	g := ghost.New(store)
+
+	tests := []struct {
+		name, contentType, reqBody string
+		expectedCode               int
+		expectedResBody            string
+	}{
+		{
+			name:            "merge patch keeps large integers",
+			contentType:     "application/merge-patch+json",
+			reqBody:         `{"note":"a"}`,
+			expectedCode:    200,
+			expectedResBody: `{"balance":9223372036854775809,"-":"d","note":"a"}`,
+		}, {
+			name:            "merge patch removes a member named -",
+			contentType:     "application/merge-patch+json",
+			reqBody:         `{"-":null}`,
+			expectedCode:    200,
+			expectedResBody: `{"balance":9223372036854775809,"-":"","note":"a"}`,
+		}, {
+			name:            "JSON patch tests large integers exactly",
+			contentType:     "application/json-patch+json",
+			reqBody:         `[{"op":"test","path":"/balance","value":9223372036854775808}]`,
+			expectedCode:    409,
+			expectedResBody: `{"error":"operation 0 (test): test failed at \"/balance\""}`,
+		}, {
+			name:            "JSON patch tests numbers by value",
+			contentType:     "application/json-patch+json",
+			reqBody:         `[{"op":"test","path":"/balance","value":9.223372036854775809e18},{"op":"replace","path":"/note","value":"b"}]`,
+			expectedCode:    200,
+			expectedResBody: `{"balance":9223372036854775809,"-":"","note":"b"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("PATCH", "/1", strings.NewReader(test.reqBody))
+			r.Header.Set("Content-Type", test.contentType)
+			g.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
+
+type RangeQuery struct {
+	MinID uint64 `schema:"min_id"`
+	IDs   []uint64
+}
+
+func TestBadRequest(t *testing.T) {
+	store := ghost.NewMapStore(User{}, RangeQuery{}, uint64(0))
+	g := ghost.New(store)
+
+	tests := []struct {
+		name, method, path, reqBody string
+		expectedCode                int
+		expectedResBody             string
+	}{
+		{
+			name:            "POST / with a malformed body",
+			method:          "POST",
+			path:            "/",
+			reqBody:         `{"Name":`,
+			expectedCode:    400,
+			expectedResBody: `{"error":"invalid request body: unexpected EOF"}`,
+		}, {
+			name:            "PUT /1 with a body of the wrong type",
+			method:          "PUT",
+			path:            "/1",
+			reqBody:         `{"Name":1}`,
+			expectedCode:    400,
+			expectedResBody: `{"error":"invalid request body: json: cannot unmarshal number into Go struct field User.Name of type string"}`,
+		}, {
+			name:            "GET /abc",
+			method:          "GET",
+			path:            "/abc",
+			expectedCode:    404,
+			expectedResBody: `{"error":"invalid key \"abc\": invalid syntax"}`,
+		}, {
+			name:            "DELETE /-1",
+			method:          "DELETE",
+			path:            "/-1",
+			expectedCode:    404,
+			expectedResBody: `{"error":"invalid key \"-1\": invalid syntax"}`,
+		}, {
+			name:            "GET / with bad query parameters",
+			method:          "GET",
+			path:            "/?min_id=x&IDs=1&IDs=y&foo=1",
+			expectedCode:    400,
+			expectedResBody: `{"error":"invalid query parameter \"IDs\": invalid value at index 1; invalid query parameter \"foo\": unknown parameter; invalid query parameter \"min_id\": invalid value"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			var body io.Reader
+			if test.method != "GET" {
+				body = strings.NewReader(test.reqBody)
+			}
+			r := httptest.NewRequest(test.method, test.path, body)
+			g.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
+
+type Contact struct {
+	Name   string
+	Phones []string
+	Labels map[string]string
+	Secret string `json:"-"`
+}
+
+func TestJSONPatch(t *testing.T) {
+	store := ghost.NewMapStore(Contact{}, SearchQuery{}, uint64(0))
+	if err := store.Create(context.Background(), &Contact{Name: "John", Phones: []string{"1", "2"}, Labels: map[string]string{"a": "x"}, Secret: "s"}); err != nil {
+		t.Fatal(err)
+	}
+	g := ghost.New(store)
+
+	tests := []struct {
+		name, method, path, reqBody string
+		expectedCode                int
+		expectedResBody             string
+	}{
+		{
+			name:   "PATCH /1",
+			method: "PATCH",
+			path:   "/1",
+			reqBody: `[
+				{"op":"test","path":"/Name","value":"John"},
+				{"op":"add","path":"/Phones/-","value":"3"},
+				{"op":"remove","path":"/Phones/0"},
+				{"op":"copy","from":"/Labels/a","path":"/Labels/b"},
+				{"op":"move","from":"/Labels/a","path":"/Labels/c"},
+				{"op":"replace","path":"/Name","value":"Bob"}
+			]`,
+			expectedCode:    200,
+			expectedResBody: `{"Name":"Bob","Phones":["2","3"],"Labels":{"b":"x","c":"x"}}`,
+		}, {
+			name:   "PATCH /1 with a failing test",
+			method: "PATCH",
+			path:   "/1",
+			reqBody: `[
+				{"op":"test","path":"/Name","value":"John"},
+				{"op":"replace","path":"/Name","value":"Alice"}
+			]`,
+			expectedCode:    409,
+			expectedResBody: `{"error":"operation 0 (test): test failed at \"/Name\""}`,
+		}, {
+			name:            "PATCH /1 removing a nonexistent member",
+			method:          "PATCH",
+			path:            "/1",
+			reqBody:         `[{"op":"remove","path":"/Labels/z"}]`,
+			expectedCode:    422,
+			expectedResBody: `{"error":"operation 0 (remove): member \"z\" not found"}`,
+		}, {
+			name:            "PATCH /1 with an unknown op",
+			method:          "PATCH",
+			path:            "/1",
+			reqBody:         `[{"op":"jump","path":"/Name"}]`,
+			expectedCode:    400,
+			expectedResBody: `{"error":"operation 0 (jump): unknown op \"jump\""}`,
+		}, {
+			name:            "GET /1",
+			method:          "GET",
+			path:            "/1",
+			expectedCode:    200,
+			expectedResBody: `{"Name":"Bob","Phones":["2","3"],"Labels":{"b":"x","c":"x"}}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			var body io.Reader
+			if test.method != "GET" {
+				body = strings.NewReader(test.reqBody)
+			}
+			r := httptest.NewRequest(test.method, test.path, body)
+			if test.method == "PATCH" {
+				r.Header.Set("Content-Type", "application/json-patch+json")
+			}
+			g.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+
+	// fields which encoding/json ignores are kept
+	c, err := store.Read(context.Background(), 1, &SearchQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, g := "s", c.Secret; e != g {
+		t.Errorf("expected Secret %s, got %s", e, g)
+	}
+}
+
+func TestJSONPatchUnknownFields(t *testing.T) {
+	store := ghost.NewMapStore(Contact{}, SearchQuery{}, uint64(0))
+	g := ghost.Ghost[Contact, SearchQuery, uint64]{
+		Server:       ghost.NewServer[Contact, SearchQuery, uint64](store, ghost.JSON[Contact]{DisallowUnknownFields: true}, ghost.PathIdentifier[uint64](ghost.UintPath[uint64]), ghost.NewQueryParser[SearchQuery]()),
+		Mux:          ghost.DefaultMux[Contact, SearchQuery],
+		ErrorHandler: ghost.DefaultErrorHandler(ghost.JSON[ghost.Error]{}),
+	}
+	if err := store.Create(context.Background(), &Contact{Name: "John"}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PATCH", "/1", strings.NewReader(`[{"op":"add","path":"/Nickname","value":"Jo"}]`))
+	r.Header.Set("Content-Type", "application/json-patch+json")
+	g.ServeHTTP(w, r)
+
+	if e, g := 422, w.Code; e != g {
+		t.Errorf("expected %d, got %d", e, g)
+	}
+	if e, g := `{"error":"json: unknown field \"Nickname\""}`, strings.TrimSpace(w.Body.String()); e != g {
+		t.Errorf("expected %s, got %s", e, g)
+	}
+}
+
+type Item struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+}
+
+func (i *Item) PKey() uint64 {
+	return i.ID
+}
+
+func (i *Item) SetPKey(id uint64) {
+	i.ID = id
+}
+
+type StrItem struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func (i *StrItem) PKey() string {
+	return i.ID
+}
+
+func (i *StrItem) SetPKey(id string) {
+	i.ID = id
+}
+
+var _ ghost.Identifiable[uint64] = &Item{}
+var _ ghost.Identifiable[string] = &StrItem{}
+
+func TestLocation(t *testing.T) {
+	t.Run("uint64", func(t *testing.T) {
+		store := ghost.NewMapStore(Item{}, SearchQuery{}, uint64(0))
+		testLocation(t, http.StripPrefix("/items", ghost.New(store)), "")
+	})
+	t.Run("string", func(t *testing.T) {
+		store := ghost.NewMapStrStore(StrItem{}, SearchQuery{}, string(""))
+		testLocation(t, http.StripPrefix("/items", ghost.NewS(store)), `"`)
+	})
+}
+
+// testLocation tests h with ids quoted with quote in the response bodies.
+func testLocation(t *testing.T, h http.Handler, quote string) {
+	tests := []struct {
+		name, method, path, reqBody string
+		expectedCode                int
+		expectedLocation            string
+		expectedResBody             string
+	}{
+		{
+			name:             "POST /items/",
+			method:           "POST",
+			path:             "/items/",
+			reqBody:          `{"name":"A"}`,
+			expectedCode:     201,
+			expectedLocation: "/items/1",
+			expectedResBody:  `{"id":%[1]s1%[1]s,"name":"A"}`,
+		}, {
+			name:             "POST /items",
+			method:           "POST",
+			path:             "/items",
+			reqBody:          `{"name":"B"}`,
+			expectedCode:     201,
+			expectedLocation: "/items/2",
+			expectedResBody:  `{"id":%[1]s2%[1]s,"name":"B"}`,
+		}, {
+			name:            "PUT /items/1",
+			method:          "PUT",
+			path:            "/items/1",
+			reqBody:         `{"name":"C"}`,
+			expectedCode:    200,
+			expectedResBody: `{"id":%[1]s1%[1]s,"name":"C"}`,
+		}, {
+			name:            "GET /items/2",
+			method:          "GET",
+			path:            "/items/2",
+			expectedCode:    200,
+			expectedResBody: `{"id":%[1]s2%[1]s,"name":"B"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			var body io.Reader
+			if test.method != "GET" {
+				body = strings.NewReader(test.reqBody)
+			}
+			r := httptest.NewRequest(test.method, test.path, body)
+			h.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := test.expectedLocation, w.Header().Get("Location"); e != g {
+				t.Errorf("expected Location %s, got %s", e, g)
+			}
+			if e, g := fmt.Sprintf(test.expectedResBody, quote), strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
+
+func TestPagination(t *testing.T) {
+	store := ghost.NewMapStore(Item{}, SearchQuery{}, uint64(0))
+	h := http.StripPrefix("/items", ghost.New(store))
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/items/", strings.NewReader(`{"name":"`+name+`"}`)))
+		if e, g := 201, w.Code; e != g {
+			t.Fatalf("expected %d, got %d", e, g)
+		}
+	}
+
+	tests := []struct {
+		name, path    string
+		expectedCode  int
+		expectedPages []string
+	}{
+		{
+			name:         "cursor",
+			path:         "/items/?limit=2",
+			expectedCode: 200,
+			expectedPages: []string{
+				`[{"id":1,"name":"a"},{"id":2,"name":"b"}]`,
+				`[{"id":3,"name":"c"},{"id":4,"name":"d"}]`,
+				`[{"id":5,"name":"e"}]`,
+			},
+		}, {
+			name:         "offset",
+			path:         "/items/?limit=3&offset=1",
+			expectedCode: 200,
+			expectedPages: []string{
+				`[{"id":2,"name":"b"},{"id":3,"name":"c"},{"id":4,"name":"d"}]`,
+				`[{"id":5,"name":"e"}]`,
+			},
+		}, {
+			name:         "no limit",
+			path:         "/items/",
+			expectedCode: 200,
+			expectedPages: []string{
+				`[{"id":1,"name":"a"},{"id":2,"name":"b"},{"id":3,"name":"c"},{"id":4,"name":"d"},{"id":5,"name":"e"}]`,
+			},
+		}, {
+			name:         "invalid limit",
+			path:         "/items/?limit=x",
+			expectedCode: 400,
+			expectedPages: []string{
+				`{"error":"invalid limit \"x\""}`,
+			},
+		}, {
+			name:         "invalid cursor",
+			path:         "/items/?cursor=x",
+			expectedCode: 400,
+			expectedPages: []string{
+				`{"error":"invalid cursor"}`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var pages []string
+			path := test.path
+			for path != "" {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+				if e, g := test.expectedCode, w.Code; e != g {
+					t.Fatalf("expected %d, got %d", e, g)
+				}
+				pages = append(pages, strings.TrimSpace(w.Body.String()))
+
+				path = ""
+				if link := w.Header().Get("Link"); link != "" {
+					if !strings.HasSuffix(link, `>; rel="next"`) {
+						t.Fatalf("unexpected Link %s", link)
+					}
+					path = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+				}
+			}
+			if diff := cmp.Diff(test.expectedPages, pages); diff != "" {
+				t.Errorf("unexpected pages (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPaging(t *testing.T) {
+	store := ghost.NewMapStore(Item{}, SearchQuery{}, uint64(0))
+	h := http.StripPrefix("/items", ghost.Ghost[Item, SearchQuery, uint64]{
+		Server: ghost.WithPaging(
+			ghost.NewServer[Item, SearchQuery, uint64](store, ghost.JSON[Item]{}, ghost.PathIdentifier[uint64](ghost.UintPath[uint64]), ghost.NewQueryParser[SearchQuery]()),
+			ghost.Paging{DefaultLimit: 2, MaxLimit: 3},
+		),
+		Mux:          ghost.DefaultMux[Item, SearchQuery],
+		ErrorHandler: ghost.DefaultErrorHandler(ghost.JSON[ghost.Error]{}),
+	})
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		if err := store.Create(context.Background(), &Item{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name, path   string
+		expectedBody string
+		expectedLink string
+	}{
+		{
+			name:         "default limit",
+			path:         "/items/",
+			expectedBody: `[{"id":1,"name":"a"},{"id":2,"name":"b"}]`,
+			expectedLink: `</items/?cursor=Mg&limit=2>; rel="next"`,
+		}, {
+			name:         "max limit",
+			path:         "/items/?limit=5",
+			expectedBody: `[{"id":1,"name":"a"},{"id":2,"name":"b"},{"id":3,"name":"c"}]`,
+			expectedLink: `</items/?cursor=Mw&limit=3>; rel="next"`,
+		}, {
+			name:         "below max limit",
+			path:         "/items/?limit=1&offset=4",
+			expectedBody: `[{"id":5,"name":"e"}]`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+			if e, g := 200, w.Code; e != g {
+				t.Fatalf("expected %d, got %d", e, g)
+			}
+			if e, g := test.expectedBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Errorf("expected %s, got %s", e, g)
+			}
+			if e, g := test.expectedLink, w.Header().Get("Link"); e != g {
+				t.Errorf("expected Link %s, got %s", e, g)
+			}
+		})
+	}
+}
+
+type Product struct {
+	Name     string `json:"name"`
+	Price    int    `json:"price"`
+	Category string `json:"category"`
+}
+
+type ProductQuery struct {
+	Name       string   `schema:"name" where:"name,like"`
+	MinPrice   int      `schema:"min_price" where:"price,gte"`
+	MaxPrice   *int     `schema:"max_price" where:"price,lte"`
+	Categories []string `schema:"category" where:"category"`
+	Sort       string   `schema:"sort" where:",sort"`
+}
+
+func TestMapStoreQuery(t *testing.T) {
+	store := ghost.NewMapStore(Product{}, ProductQuery{}, uint64(0))
+	g := ghost.New(store)
+	for _, body := range []string{
+		`{"name":"carrot","price":30,"category":"vegetable"}`,
+		`{"name":"apple","price":0,"category":"fruit"}`,
+		`{"name":"bread","price":40,"category":"bakery"}`,
+		`{"name":"banana","price":20,"category":"fruit"}`,
+	} {
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		if e, g := 201, w.Code; e != g {
+			t.Fatalf("expected %d, got %d", e, g)
+		}
+	}
+
+	tests := []struct {
+		name, path      string
+		expectedCode    int
+		expectedResBody string
+	}{
+		{
+			name:            "insertion order",
+			path:            "/",
+			expectedCode:    200,
+			expectedResBody: `["carrot","apple","bread","banana"]`,
+		}, {
+			name:            "like",
+			path:            "/?name=b%25",
+			expectedCode:    200,
+			expectedResBody: `["bread","banana"]`,
+		}, {
+			name:            "like is case insensitive",
+			path:            "/?name=B%25",
+			expectedCode:    200,
+			expectedResBody: `["bread","banana"]`,
+		}, {
+			name:            "range",
+			path:            "/?min_price=20&max_price=30",
+			expectedCode:    200,
+			expectedResBody: `["carrot","banana"]`,
+		}, {
+			name:            "zero pointer",
+			path:            "/?max_price=0",
+			expectedCode:    200,
+			expectedResBody: `["apple"]`,
+		}, {
+			name:            "in",
+			path:            "/?category=fruit&category=bakery",
+			expectedCode:    200,
+			expectedResBody: `["apple","bread","banana"]`,
+		}, {
+			name:            "sort",
+			path:            "/?sort=category,-price",
+			expectedCode:    200,
+			expectedResBody: `["bread","banana","apple","carrot"]`,
+		}, {
+			name:            "sort and paginate",
+			path:            "/?sort=name&limit=2&offset=1",
+			expectedCode:    200,
+			expectedResBody: `["banana","bread"]`,
+		}, {
+			name:            "unknown sort key",
+			path:            "/?sort=weight",
+			expectedCode:    400,
+			expectedResBody: `{"error":"unknown sort key \"weight\""}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			body := strings.TrimSpace(w.Body.String())
+			if w.Code == 200 {
+				var products []Product
+				if err := json.Unmarshal([]byte(body), &products); err != nil {
+					t.Fatalf("failed to decode json body: %v", err)
+				}
+				names := []string{}
+				for _, p := range products {
+					names = append(names, p.Name)
+				}
+				b, _ := json.Marshal(names)
+				body = string(b)
+			}
+			if e, g := test.expectedResBody, body; e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
+
 type HookedUser struct {
 	Name   string
 	Called map[string]int
@@ -219,10 +954,10 @@ func TestHook(t *testing.T) {
 			method:          "DELETE",
 			path:            "/1",
 			expectedCode:    204,
-			expectedResBody: `{}`,
+			expectedResBody: ``,
 		}, {
-			name:            "PATCH /1",
-			method:          "PATCH",
+			name:            "TRACE /1",
+			method:          "TRACE",
 			path:            "/1",
 			expectedCode:    405,
 			expectedResBody: `{"error":"Method Not Allowed"}`,
@@ -256,65 +991,3 @@ func TestHook(t *testing.T) {
 		t.Errorf("unexpected calls to hooks (-want +got):\n%s", diff)
 	}
 }
-
-type ValidateUser struct {
-	Name string `validate:"required"`
-}
-
-type ValidateSearchQuery struct {
-	Name string `validate:"required"`
-}
-
-func TestValidate(t *testing.T) {
-	store := ghost.NewMapStore(ValidateUser{}, ValidateSearchQuery{}, uint64(0))
-	validator := validator.New()
-	store = v.NewStore(store, validator)
-	g := ghost.New(store)
-
-	tests := []struct {
-		name, method, path, reqBody string
-		expectedCode                int
-		expectedResBody             string
-	}{
-		{
-			name:            "POST /",
-			method:          "POST",
-			path:            "/",
-			reqBody:         `{"Name":""}`,
-			expectedCode:    400,
-			expectedResBody: `{"error":"Key: 'ValidateUser.Name' Error:Field validation for 'Name' failed on the 'required' tag"}`,
-		}, {
-			name:            "PUT /1",
-			method:          "PUT",
-			path:            "/1",
-			reqBody:         `{"Name":""}`,
-			expectedCode:    400,
-			expectedResBody: `{"error":"Key: 'ValidateUser.Name' Error:Field validation for 'Name' failed on the 'required' tag"}`,
-		}, {
-			name:            "GET /",
-			method:          "GET",
-			path:            "/",
-			reqBody:         `{"Name":""}`,
-			expectedCode:    400,
-			expectedResBody: `{"error":"Key: 'ValidateSearchQuery.Name' Error:Field validation for 'Name' failed on the 'required' tag"}`,
-		},
-	}
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			w := httptest.NewRecorder()
-			var body io.Reader
-			if test.method != "GET" {
-				body = strings.NewReader(test.reqBody)
-			}
-			r := httptest.NewRequest(test.method, test.path, body)
-			g.ServeHTTP(w, r)
-
-			if e, g := test.expectedCode, w.Code; e != g {
-				t.Errorf("expected %d, got %d", e, g)
-			}
-			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
-				t.Fatalf("expected %s, got %s", e, g)
-			}
-		})
-	}
-}
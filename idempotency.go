@@ -0,0 +1,258 @@
+package ghost
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader is the request header which makes a POST request idempotent, see Idempotent.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentResponse is a response stored for an Idempotency-Key.
+type IdempotentResponse struct {
+	Code   int
+	Header http.Header
+	Body   []byte
+}
+
+// IdempotencyStore stores the responses of requests by their Idempotency-Key.
+type IdempotencyStore interface {
+	// Begin records key as in flight for the request whose hash is hash, and returns nil if key is new.
+	// It returns the stored response if key was completed with the same hash,
+	// ErrIdempotencyKeyReused if it was used with a different hash,
+	// and ErrIdempotencyKeyInFlight if it isn't completed.
+	Begin(ctx context.Context, key, hash string) (*IdempotentResponse, error)
+	// Complete stores the response of key.
+	Complete(ctx context.Context, key string, res IdempotentResponse) error
+	// Abort forgets key, so that the request can be retried.
+	Abort(ctx context.Context, key string) error
+}
+
+var ErrIdempotencyKeyReused = Error{
+	Code: http.StatusConflict,
+	Err:  errors.New("Idempotency-Key is already used with a different request"),
+}
+
+var ErrIdempotencyKeyInFlight = Error{
+	Code: http.StatusConflict,
+	Err:  errors.New("a request with the Idempotency-Key is in progress"),
+}
+
+// DefaultIdempotencyMaxBytes is the MaxBytes of Idempotency if it is 0.
+var DefaultIdempotencyMaxBytes int64 = 1 << 20
+
+// Idempotency configures the processing of POST requests with an Idempotency-Key header, see Handler.
+type Idempotency struct {
+	// Store stores the responses.
+	Store IdempotencyStore
+	// ErrorHandler handles errors, DefaultErrorHandler with JSON if nil.
+	ErrorHandler func(error) http.Handler
+	// MaxBytes limits the size of the request bodies, which are read into memory to be hashed,
+	// larger ones are ErrRequestEntityTooLarge. DefaultIdempotencyMaxBytes if 0, negative means no limit.
+	MaxBytes int64
+}
+
+// Idempotent wraps next with the Idempotency of store and errorHandler, see Idempotency.Handler.
+func Idempotent(next http.Handler, store IdempotencyStore, errorHandler func(error) http.Handler) http.Handler {
+	return Idempotency{Store: store, ErrorHandler: errorHandler}.Handler(next)
+}
+
+// Handler wraps next so that POST requests with an Idempotency-Key header are processed once.
+// The first response is stored in the Store and replayed for repeated requests with the same key,
+// with the Idempotent-Replayed header.
+// Requests are identified by their method, path and body, so the same key with another request is ErrIdempotencyKeyReused.
+// 5xx responses aren't stored, so that the request can be retried.
+func (i Idempotency) Handler(next http.Handler) http.Handler {
+	errorHandler := i.ErrorHandler
+	if errorHandler == nil {
+		errorHandler = DefaultErrorHandler(JSON[Error]{})
+	}
+	maxBytes := i.MaxBytes
+	if maxBytes == 0 {
+		maxBytes = DefaultIdempotencyMaxBytes
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if maxBytes > 0 {
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{&maxBytesReader{r: r.Body, n: maxBytes}, r.Body}
+		}
+		if err := idempotent(w, r, next, i.Store, key); err != nil {
+			errorHandler(err).ServeHTTP(w, r)
+		}
+	})
+}
+
+func idempotent(w http.ResponseWriter, r *http.Request, next http.Handler, store IdempotencyStore, key string) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		if errors.Is(err, ErrRequestEntityTooLarge) {
+			return err
+		}
+		return bodyError(err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	stored, err := store.Begin(r.Context(), key, requestHash(r, body))
+	if err != nil {
+		return err
+	}
+	if stored != nil {
+		replay(w, *stored)
+		return nil
+	}
+
+	// the key has to be completed or aborted even if the client is gone, or it stays in flight
+	ctx := withoutCancel{r.Context()}
+	done := false
+	defer func() {
+		if !done {
+			// next panicked
+			_ = store.Abort(ctx, key)
+		}
+	}()
+	var b bufferedResponse
+	next.ServeHTTP(&b, r)
+	done = true
+	if b.code == 0 {
+		b.code = http.StatusOK
+	}
+	res := IdempotentResponse{Code: b.code, Header: b.Header().Clone(), Body: b.body.Bytes()}
+	if res.Code >= http.StatusInternalServerError {
+		err = store.Abort(ctx, key)
+	} else {
+		err = store.Complete(ctx, key, res)
+	}
+	if err != nil {
+		return err
+	}
+	copyHeader(w.Header(), res.Header)
+	w.WriteHeader(res.Code)
+	_, err = w.Write(res.Body)
+	return err
+}
+
+// withoutCancel is a context with the values of its parent, which is never canceled.
+type withoutCancel struct {
+	context.Context
+}
+
+func (withoutCancel) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (withoutCancel) Done() <-chan struct{} {
+	return nil
+}
+
+func (withoutCancel) Err() error {
+	return nil
+}
+
+func replay(w http.ResponseWriter, res IdempotentResponse) {
+	copyHeader(w.Header(), res.Header)
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(res.Code)
+	_, _ = w.Write(res.Body)
+}
+
+func copyHeader(dst, src http.Header) {
+	for k, vs := range src {
+		dst[k] = append([]string(nil), vs...)
+	}
+}
+
+// requestHash returns the hash of the method, path and body of r.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	_, _ = io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	_, _ = h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type idempotencyEntry struct {
+	hash    string
+	res     *IdempotentResponse
+	expires time.Time
+}
+
+func (e *idempotencyEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+type mapIdempotencyStore struct {
+	ttl     time.Duration
+	entries map[string]*idempotencyEntry
+	// swept is when the expired entries were last deleted
+	swept time.Time
+	lock  sync.Mutex
+}
+
+// NewMapIdempotencyStore returns an in-memory IdempotencyStore.
+// Keys expire ttl after they begin, or never if ttl is 0.
+// Expired keys are deleted at most once per ttl, so that Begin doesn't scan every key.
+func NewMapIdempotencyStore(ttl time.Duration) IdempotencyStore {
+	return &mapIdempotencyStore{
+		ttl:     ttl,
+		entries: map[string]*idempotencyEntry{},
+		swept:   time.Now(),
+	}
+}
+
+func (s *mapIdempotencyStore) Begin(ctx context.Context, key, hash string) (*IdempotentResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	if s.ttl > 0 && now.Sub(s.swept) >= s.ttl {
+		for k, e := range s.entries {
+			if e.expired(now) {
+				delete(s.entries, k)
+			}
+		}
+		s.swept = now
+	}
+	if e, ok := s.entries[key]; ok && !e.expired(now) {
+		if e.hash != hash {
+			return nil, ErrIdempotencyKeyReused
+		}
+		if e.res == nil {
+			return nil, ErrIdempotencyKeyInFlight
+		}
+		return e.res, nil
+	}
+	e := &idempotencyEntry{hash: hash}
+	if s.ttl > 0 {
+		e.expires = now.Add(s.ttl)
+	}
+	s.entries[key] = e
+	return nil, nil
+}
+
+func (s *mapIdempotencyStore) Complete(ctx context.Context, key string, res IdempotentResponse) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if e, ok := s.entries[key]; ok {
+		e.res = &res
+	}
+	return nil
+}
+
+func (s *mapIdempotencyStore) Abort(ctx context.Context, key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.entries, key)
+	return nil
+}
@@ -0,0 +1,126 @@
+package ghost_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mash/ghost"
+)
+
+func TestIdempotent(t *testing.T) {
+	var h http.Handler
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			r.Header.Set(ghost.IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	expect := func(w *httptest.ResponseRecorder, code int, body string) {
+		t.Helper()
+		if e, g := code, w.Code; e != g {
+			t.Errorf("expected %d, got %d", e, g)
+		}
+		if e, g := body, strings.TrimSpace(w.Body.String()); e != g {
+			t.Errorf("expected %s, got %s", e, g)
+		}
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		h = ghost.Idempotent(ghost.New(ghost.NewMapStore(User{}, SearchQuery{}, uint64(0))), ghost.NewMapIdempotencyStore(0), nil)
+
+		w := do("POST", "/", "a", `{"Name":"John"}`)
+		expect(w, 201, `{"Name":"John"}`)
+		if e, g := "", w.Header().Get("Idempotent-Replayed"); e != g {
+			t.Errorf("expected %q, got %q", e, g)
+		}
+
+		w = do("POST", "/", "a", `{"Name":"John"}`)
+		expect(w, 201, `{"Name":"John"}`)
+		if e, g := "true", w.Header().Get("Idempotent-Replayed"); e != g {
+			t.Errorf("expected %q, got %q", e, g)
+		}
+		if e, g := "application/json", w.Header().Get("Content-Type"); e != g {
+			t.Errorf("expected %q, got %q", e, g)
+		}
+
+		expect(do("POST", "/", "a", `{"Name":"Bob"}`), 409, `{"error":"Idempotency-Key is already used with a different request"}`)
+		expect(do("POST", "/", "b", `{"Name":"John"}`), 201, `{"Name":"John"}`)
+		expect(do("POST", "/", "", `{"Name":"John"}`), 201, `{"Name":"John"}`)
+
+		// only the requests with new keys or without keys created users
+		expect(do("GET", "/", "", ""), 200, `[{"Name":"John"},{"Name":"John"},{"Name":"John"}]`)
+	})
+
+	t.Run("in flight", func(t *testing.T) {
+		var inner *httptest.ResponseRecorder
+		h = ghost.Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if inner == nil {
+				inner = do("POST", "/", "a", `{}`)
+			}
+			w.WriteHeader(http.StatusCreated)
+		}), ghost.NewMapIdempotencyStore(0), nil)
+
+		expect(do("POST", "/", "a", `{}`), 201, ``)
+		expect(inner, 409, `{"error":"a request with the Idempotency-Key is in progress"}`)
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		calls := 0
+		h = ghost.Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		}), ghost.NewMapIdempotencyStore(0), nil)
+
+		expect(do("POST", "/", "a", `{}`), 503, ``)
+		expect(do("POST", "/", "a", `{}`), 201, ``)
+		expect(do("POST", "/", "a", `{}`), 201, ``)
+		if e, g := 2, calls; e != g {
+			t.Errorf("expected %d, got %d", e, g)
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		calls := 0
+		h = ghost.Idempotency{
+			Store:    ghost.NewMapIdempotencyStore(0),
+			MaxBytes: 4,
+		}.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusCreated)
+		}))
+
+		expect(do("POST", "/", "a", `{"Name":"John"}`), 413, `{"error":"Request Entity Too Large"}`)
+		expect(do("POST", "/", "a", `{}`), 201, ``)
+		if e, g := 1, calls; e != g {
+			t.Errorf("expected %d, got %d", e, g)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		calls := 0
+		h = ghost.Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusCreated)
+		}), ghost.NewMapIdempotencyStore(10*time.Millisecond), nil)
+
+		expect(do("POST", "/", "a", `{}`), 201, ``)
+		expect(do("POST", "/", "a", `{}`), 201, ``)
+		time.Sleep(20 * time.Millisecond)
+		// the key can be used for another request
+		expect(do("POST", "/", "a", `{"Name":"John"}`), 201, ``)
+		if e, g := 2, calls; e != g {
+			t.Errorf("expected %d, got %d", e, g)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package ghost
 
 import (
+	"errors"
 	"net/http"
 	"path"
 	"strconv"
@@ -25,19 +26,37 @@ type Identifier[P PKey] interface {
 }
 
 // PathIdentifier is an Identifier which extracts the PKey from the request URL path.
+// Keys which can't be parsed don't identify any resource, so they are 404 Not Found Errors with a PathKeyError.
 type PathIdentifier[P PKey] func(string) (P, error)
 
 func (pi PathIdentifier[P]) PKey(r *http.Request) (P, error) {
-	var p P
 	_, lastpath := path.Split(r.URL.Path)
-	if lastpath != "" {
-		return pi(lastpath)
+	if lastpath == "" {
+		var p P
+		return p, ErrNotFound
 	}
-	return p, ErrNotFound
+	return pi.parse(lastpath)
+}
+
+// parse parses a path segment into a PKey.
+func (pi PathIdentifier[P]) parse(s string) (P, error) {
+	p, err := pi(s)
+	if err != nil {
+		var e Error
+		if errors.As(err, &e) {
+			return p, err
+		}
+		return p, Error{Code: http.StatusNotFound, Err: PathKeyError{Key: s, Err: err}}
+	}
+	return p, nil
 }
 
 func UintPath[P PUintKey](s string) (P, error) {
 	i, err := strconv.ParseUint(s, 10, 64)
+	if ne, ok := err.(*strconv.NumError); ok {
+		// the key is in PathKeyError
+		err = ne.Err
+	}
 	return P(i), err
 }
 
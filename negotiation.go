@@ -0,0 +1,163 @@
+package ghost
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var ErrNotAcceptable = Error{
+	Code: http.StatusNotAcceptable,
+	Err:  errors.New(http.StatusText(http.StatusNotAcceptable)),
+}
+
+// errNoEncodings is returned by the Encodings methods if there are no Encodings, which is a 500 Internal Server Error.
+var errNoEncodings = errors.New("no Encodings")
+
+// MediaTyper is implemented by Encodings which know the media type they encode to and decode from.
+type MediaTyper interface {
+	MediaType() string
+}
+
+// mediaType returns the media type of enc, or empty if it isn't a MediaTyper.
+func mediaType(enc any) string {
+	if m, ok := enc.(MediaTyper); ok {
+		return m.MediaType()
+	}
+	return ""
+}
+
+// Encodings is a registry of Encodings keyed by their media types, see MediaTyper.
+// The first Encoding is the default, which is used when the request doesn't specify a media type.
+// Encodings which aren't MediaTypers match any media type, but only if no MediaTyper does.
+type Encodings[R Resource] []Encoding[R]
+
+// Lookup returns the Encoding of the media type.
+func (e Encodings[R]) Lookup(mediaType string) (Encoding[R], bool) {
+	for _, enc := range e {
+		if m, ok := enc.(MediaTyper); ok && strings.EqualFold(m.MediaType(), mediaType) {
+			return enc, true
+		}
+	}
+	return nil, false
+}
+
+// any returns the first Encoding which isn't a MediaTyper.
+func (e Encodings[R]) any() (Encoding[R], bool) {
+	for _, enc := range e {
+		if _, ok := enc.(MediaTyper); !ok {
+			return enc, true
+		}
+	}
+	return nil, false
+}
+
+// Request returns the Encoding of the request body, selected by the Content-Type header.
+// It returns the default Encoding if there is no Content-Type, and ErrUnsupportedMediaType if no Encoding matches.
+// Empty Encodings are an error.
+func (e Encodings[R]) Request(r *http.Request) (Encoding[R], error) {
+	if len(e) == 0 {
+		return nil, errNoEncodings
+	}
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return e[0], nil
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return nil, ErrUnsupportedMediaType
+	}
+	if enc, ok := e.Lookup(mt); ok {
+		return enc, nil
+	}
+	if enc, ok := e.any(); ok {
+		return enc, nil
+	}
+	return nil, ErrUnsupportedMediaType
+}
+
+// Response returns the Encoding of the response, negotiated by the Accept header.
+// Among the Encodings with the highest quality value, the one registered first wins.
+// If none is acceptable it returns ErrNotAcceptable along with the default Encoding, which can be used to encode the error.
+// Empty Encodings are an error, without an Encoding.
+func (e Encodings[R]) Response(r *http.Request) (Encoding[R], error) {
+	if len(e) == 0 {
+		return nil, errNoEncodings
+	}
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return e[0], nil
+	}
+	ranges := parseAccept(strings.Join(accept, ","))
+	var best Encoding[R]
+	var bestQ float64
+	for _, enc := range e {
+		if _, ok := enc.(MediaTyper); !ok {
+			continue
+		}
+		if q := quality(ranges, mediaType(enc)); q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	if best == nil {
+		if enc, ok := e.any(); ok {
+			return enc, nil
+		}
+		return e[0], ErrNotAcceptable
+	}
+	return best, nil
+}
+
+// mediaRange is a media range in an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, s := range strings.Split(accept, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		mt, params, err := mime.ParseMediaType(s)
+		if err != nil {
+			continue
+		}
+		typ, subtype, _ := strings.Cut(mt, "/")
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, q: q})
+	}
+	return ranges
+}
+
+// quality returns the quality value of the most specific media range which matches mediaType.
+func quality(ranges []mediaRange, mediaType string) float64 {
+	typ, subtype, _ := strings.Cut(strings.ToLower(mediaType), "/")
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		var s int
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 2
+		case r.typ == typ && r.subtype == "*":
+			s = 1
+		case r.typ == "*" && r.subtype == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
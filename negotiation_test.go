@@ -0,0 +1,295 @@
+package ghost_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mash/ghost"
+)
+
+// userText is a text/plain Encoding of User.
+type userText struct{}
+
+func (userText) MediaType() string {
+	return "text/plain"
+}
+
+func (userText) Encode(w http.ResponseWriter, u User, code int) error {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(code)
+	_, err := fmt.Fprintf(w, "Name: %s\n", u.Name)
+	return err
+}
+
+func (userText) EncodeList(w http.ResponseWriter, us []User, code int) error {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(code)
+	for _, u := range us {
+		if _, err := fmt.Fprintf(w, "Name: %s\n", u.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (userText) EncodeEmpty(w http.ResponseWriter, code int) error {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(code)
+	return nil
+}
+
+func (userText) Decode(r *http.Request) (User, error) {
+	b, err := io.ReadAll(r.Body)
+	return User{Name: strings.TrimSpace(string(b))}, err
+}
+
+// errorText is a text/plain Encoding of Error.
+type errorText struct{}
+
+func (errorText) MediaType() string {
+	return "text/plain"
+}
+
+func (errorText) Encode(w http.ResponseWriter, e ghost.Error, code int) error {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(code)
+	_, err := fmt.Fprintf(w, "error: %s\n", e.Err)
+	return err
+}
+
+func (errorText) EncodeList(w http.ResponseWriter, es []ghost.Error, code int) error {
+	return fmt.Errorf("not supported")
+}
+
+func (errorText) EncodeEmpty(w http.ResponseWriter, code int) error {
+	return fmt.Errorf("not supported")
+}
+
+func (errorText) Decode(r *http.Request) (ghost.Error, error) {
+	return ghost.Error{}, fmt.Errorf("not supported")
+}
+
+func TestNegotiation(t *testing.T) {
+	store := ghost.NewHookStore(ghost.NewMapStore(User{}, SearchQuery{}, uint64(0)))
+	g := ghost.Ghost[User, SearchQuery, uint64]{
+		Server: ghost.NewNegotiatingServer[User, SearchQuery, uint64](
+			store,
+			ghost.Encodings[User]{ghost.JSON[User]{}, userText{}},
+			ghost.PathIdentifier[uint64](ghost.UintPath[uint64]),
+			ghost.NewQueryParser[SearchQuery](),
+		),
+		Mux:          ghost.DefaultMux[User, SearchQuery],
+		ErrorHandler: ghost.NegotiatingErrorHandler(ghost.Encodings[ghost.Error]{ghost.JSON[ghost.Error]{}, errorText{}}),
+	}
+
+	tests := []struct {
+		name, method, path, contentType, accept, reqBody string
+		expectedCode                                     int
+		expectedContentType                              string
+		expectedResBody                                  string
+	}{
+		{
+			name:                "POST / text to json",
+			method:              "POST",
+			path:                "/",
+			contentType:         "text/plain; charset=utf-8",
+			accept:              "application/json",
+			reqBody:             "John",
+			expectedCode:        201,
+			expectedContentType: "application/json",
+			expectedResBody:     `{"Name":"John"}`,
+		}, {
+			name:                "GET /1 without Accept",
+			method:              "GET",
+			path:                "/1",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			expectedResBody:     `{"Name":"John"}`,
+		}, {
+			name:                "GET /1 as text",
+			method:              "GET",
+			path:                "/1",
+			accept:              "text/plain",
+			expectedCode:        200,
+			expectedContentType: "text/plain",
+			expectedResBody:     `Name: John`,
+		}, {
+			name:                "GET /1 with quality values",
+			method:              "GET",
+			path:                "/1",
+			accept:              "application/*;q=0.5, text/*",
+			expectedCode:        200,
+			expectedContentType: "text/plain",
+			expectedResBody:     `Name: John`,
+		}, {
+			name:                "GET /1 with wildcard",
+			method:              "GET",
+			path:                "/1",
+			accept:              "*/*",
+			expectedCode:        200,
+			expectedContentType: "application/json",
+			expectedResBody:     `{"Name":"John"}`,
+		}, {
+			name:                "GET /1 not acceptable",
+			method:              "GET",
+			path:                "/1",
+			accept:              "application/xml",
+			expectedCode:        406,
+			expectedContentType: "application/json",
+			expectedResBody:     `{"error":"Not Acceptable"}`,
+		}, {
+			name:                "GET /2 error as text",
+			method:              "GET",
+			path:                "/2",
+			accept:              "text/plain",
+			expectedCode:        404,
+			expectedContentType: "text/plain",
+			expectedResBody:     `error: Not Found`,
+		}, {
+			name:                "PUT /1 unsupported media type",
+			method:              "PUT",
+			path:                "/1",
+			contentType:         "application/xml",
+			reqBody:             `<User><Name>Bob</Name></User>`,
+			expectedCode:        415,
+			expectedContentType: "application/json",
+			expectedResBody:     `{"error":"Unsupported Media Type"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			var body io.Reader
+			if test.method != "GET" {
+				body = strings.NewReader(test.reqBody)
+			}
+			r := httptest.NewRequest(test.method, test.path, body)
+			if test.contentType != "" {
+				r.Header.Set("Content-Type", test.contentType)
+			}
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			g.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := test.expectedContentType, w.Header().Get("Content-Type"); e != g {
+				t.Errorf("expected Content-Type %s, got %s", e, g)
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
+
+// plainText is userText without a MediaType, like Encodings which predate MediaTyper.
+type plainText struct{}
+
+func (plainText) Encode(w http.ResponseWriter, u User, code int) error {
+	return userText{}.Encode(w, u, code)
+}
+
+func (plainText) EncodeList(w http.ResponseWriter, us []User, code int) error {
+	return userText{}.EncodeList(w, us, code)
+}
+
+func (plainText) EncodeEmpty(w http.ResponseWriter, code int) error {
+	return userText{}.EncodeEmpty(w, code)
+}
+
+func (plainText) Decode(r *http.Request) (User, error) {
+	return userText{}.Decode(r)
+}
+
+func TestEncodingWithoutMediaType(t *testing.T) {
+	store := ghost.NewMapStore(User{}, SearchQuery{}, uint64(0))
+	_ = store.Create(context.Background(), &User{Name: "John"})
+	g := ghost.Ghost[User, SearchQuery, uint64]{
+		Server:       ghost.NewServer[User, SearchQuery, uint64](store, plainText{}, ghost.PathIdentifier[uint64](ghost.UintPath[uint64]), ghost.NewQueryParser[SearchQuery]()),
+		Mux:          ghost.DefaultMux[User, SearchQuery],
+		ErrorHandler: ghost.DefaultErrorHandler(ghost.JSON[ghost.Error]{}),
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/1", nil)
+	r.Header.Set("Accept", "application/xml")
+	g.ServeHTTP(w, r)
+
+	if e, g := 200, w.Code; e != g {
+		t.Errorf("expected %d, got %d", e, g)
+	}
+	if e, g := "Name: John", strings.TrimSpace(w.Body.String()); e != g {
+		t.Errorf("expected %s, got %s", e, g)
+	}
+}
+
+func TestEmptyEncodings(t *testing.T) {
+	store := ghost.NewMapStore(User{}, SearchQuery{}, uint64(0))
+	_ = store.Create(context.Background(), &User{Name: "John"})
+	server := ghost.NewNegotiatingServer[User, SearchQuery, uint64](store, ghost.Encodings[User]{}, ghost.PathIdentifier[uint64](ghost.UintPath[uint64]), ghost.NewQueryParser[SearchQuery]())
+
+	tests := []struct {
+		name, method, path, reqBody string
+		errorHandler                func(error) http.Handler
+		expectedCode                int
+		expectedResBody             string
+	}{
+		{
+			name:            "GET /1",
+			method:          "GET",
+			path:            "/1",
+			errorHandler:    ghost.DefaultErrorHandler(ghost.JSON[ghost.Error]{}),
+			expectedCode:    500,
+			expectedResBody: `{"error":"no Encodings"}`,
+		}, {
+			name:            "POST /",
+			method:          "POST",
+			path:            "/",
+			reqBody:         `{"Name":"Paul"}`,
+			errorHandler:    ghost.DefaultErrorHandler(ghost.JSON[ghost.Error]{}),
+			expectedCode:    500,
+			expectedResBody: `{"error":"no Encodings"}`,
+		}, {
+			name:            "GET /1 without error Encodings",
+			method:          "GET",
+			path:            "/1",
+			errorHandler:    ghost.NegotiatingErrorHandler(ghost.Encodings[ghost.Error]{}),
+			expectedCode:    500,
+			expectedResBody: `Internal Server Error`,
+		}, {
+			name:            "GET /1 without problem Encodings",
+			method:          "GET",
+			path:            "/1",
+			errorHandler:    ghost.ProblemErrorHandler(ghost.Encodings[ghost.Problem]{}),
+			expectedCode:    500,
+			expectedResBody: `Internal Server Error`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := ghost.Ghost[User, SearchQuery, uint64]{
+				Server:       server,
+				Mux:          ghost.DefaultMux[User, SearchQuery],
+				ErrorHandler: test.errorHandler,
+			}
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.reqBody))
+			g.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package ghost
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Nested is a http.Handler of parent resources with child resources, such as /users/{id}/posts/{postId}.
+// It serves Parent for / and /{pkey}.
+// For /{pkey}/{name}/..., it reads the parent with Store, which is ErrNotFound if it doesn't exist,
+// and serves Children[name] with the path /... and the parent in the context, see Parent.
+// Children which are Child of P are scoped to the parent, see InParent.
+// Nested handles paths relative to where it is mounted, so mount it with http.StripPrefix, like Ghost.
+// Children can be Nested too.
+type Nested[R Resource, Q Query, P PKey] struct {
+	Parent       http.Handler
+	Store        Store[R, Q, P]
+	Key          PathIdentifier[P]
+	Children     map[string]http.Handler
+	ErrorHandler func(error) http.Handler
+}
+
+// Nest returns a Nested which serves the resources of store with New.
+// Nest requires PKey to be an integer.
+func Nest[R Resource, Q Query, P PUintKey](store Store[R, Q, P], children map[string]http.Handler) http.Handler {
+	return Nested[R, Q, P]{
+		Parent:       New(store),
+		Store:        NewHookStore(store),
+		Key:          PathIdentifier[P](UintPath[P]),
+		Children:     children,
+		ErrorHandler: DefaultErrorHandler(JSON[Error]{}),
+	}
+}
+
+// NestS returns a Nested which serves the resources of store with NewS.
+// NestS requires PKey to be a string.
+func NestS[R Resource, Q Query, P PStrKey](store Store[R, Q, P], children map[string]http.Handler) http.Handler {
+	return Nested[R, Q, P]{
+		Parent:       NewS(store),
+		Store:        NewHookStore(store),
+		Key:          PathIdentifier[P](StrPath[P]),
+		Children:     children,
+		ErrorHandler: DefaultErrorHandler(JSON[Error]{}),
+	}
+}
+
+func (n Nested[R, Q, P]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the escaped path, so that escaped slashes in keys don't split segments
+	segments := strings.SplitN(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/", 3)
+	if len(segments) < 2 {
+		n.Parent.ServeHTTP(w, r)
+		return
+	}
+	if err := n.child(w, r, segments); err != nil {
+		n.ErrorHandler(err).ServeHTTP(w, r)
+	}
+}
+
+func (n Nested[R, Q, P]) child(w http.ResponseWriter, r *http.Request, segments []string) error {
+	child, ok := n.Children[segments[1]]
+	if !ok {
+		return ErrNotFound
+	}
+	key, err := url.PathUnescape(segments[0])
+	if err != nil {
+		return Error{Code: http.StatusNotFound, Err: PathKeyError{Key: segments[0], Err: err}}
+	}
+	pkey, err := n.Key.parse(key)
+	if err != nil {
+		return err
+	}
+	var q Q
+	parent, err := n.Store.Read(r.Context(), pkey, &q)
+	if err != nil {
+		return err
+	}
+
+	rest := "/"
+	if len(segments) == 3 {
+		rest += segments[2]
+	}
+	u := *r.URL
+	u.RawPath = rest
+	if u.Path, err = url.PathUnescape(rest); err != nil {
+		return ErrNotFound
+	}
+	if u.Path == u.RawPath {
+		u.RawPath = ""
+	}
+	ctx := context.WithValue(r.Context(), parentKey[R]{}, parentValue[R, P]{r: parent, pkey: pkey})
+	ctx = context.WithValue(ctx, scopeKey{}, scope{
+		owns: func(r any) bool {
+			c, ok := r.(Child[P])
+			return !ok || c.ParentKey() == pkey
+		},
+		adopt: func(r any) {
+			if c, ok := r.(Child[P]); ok {
+				c.SetParentKey(pkey)
+			}
+		},
+	})
+	r2 := r.WithContext(ctx)
+	r2.URL = &u
+	child.ServeHTTP(w, r2)
+	return nil
+}
+
+type parentKey[R Resource] struct{}
+
+type parentValue[R Resource, P PKey] struct {
+	r    *R
+	pkey P
+}
+
+// Parent returns the parent resource of type R and its PKey, which Nested read for the request of ctx,
+// and whether there is one.
+func Parent[R Resource, P PKey](ctx context.Context) (*R, P, bool) {
+	v, ok := ctx.Value(parentKey[R]{}).(parentValue[R, P])
+	return v.r, v.pkey, ok
+}
+
+type scopeKey struct{}
+
+// scope is the innermost parent of a request, which doesn't depend on the type of the parent.
+type scope struct {
+	owns  func(r any) bool
+	adopt func(r any)
+}
+
+// InParent reports whether r, a pointer to a resource, belongs to the parent which Nested read for the request of ctx.
+// It is true if there is no parent, or if r isn't a Child of the PKey of the parent.
+// Stores call it to serve resources of other parents as if they don't exist.
+func InParent(ctx context.Context, r any) bool {
+	s, ok := ctx.Value(scopeKey{}).(scope)
+	return !ok || s.owns(r)
+}
+
+// SetParent sets the ParentKey of r, a pointer to a resource, to the PKey of the parent which Nested read for the request of ctx,
+// if there is one and r is a Child of its PKey.
+func SetParent(ctx context.Context, r any) {
+	if s, ok := ctx.Value(scopeKey{}).(scope); ok {
+		s.adopt(r)
+	}
+}
@@ -0,0 +1,195 @@
+package ghost_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mash/ghost"
+)
+
+type Post struct {
+	ID     uint64 `json:"id"`
+	UserID uint64 `json:"user_id"`
+	Title  string `json:"title"`
+}
+
+func (p *Post) PKey() uint64 {
+	return p.ID
+}
+
+func (p *Post) SetPKey(id uint64) {
+	p.ID = id
+}
+
+func (p *Post) ParentKey() uint64 {
+	return p.UserID
+}
+
+func (p *Post) SetParentKey(userID uint64) {
+	p.UserID = userID
+}
+
+type PostQuery struct {
+	Title string `where:"title"`
+}
+
+func TestNest(t *testing.T) {
+	posts := ghost.New(ghost.NewMapStore(Post{}, PostQuery{}, uint64(0)))
+	users := ghost.Nest(ghost.NewMapStore(User{}, SearchQuery{}, uint64(0)), map[string]http.Handler{
+		"posts": posts,
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/users/", http.StripPrefix("/users", users))
+
+	tests := []struct {
+		name, method, path, reqBody string
+		expectedCode                int
+		expectedLocation            string
+		expectedResBody             string
+	}{
+		{
+			name:            "POST /users/",
+			method:          "POST",
+			path:            "/users/",
+			reqBody:         `{"Name":"John"}`,
+			expectedCode:    201,
+			expectedResBody: `{"Name":"John"}`,
+		}, {
+			name:            "POST /users/ again",
+			method:          "POST",
+			path:            "/users/",
+			reqBody:         `{"Name":"Bob"}`,
+			expectedCode:    201,
+			expectedResBody: `{"Name":"Bob"}`,
+		}, {
+			name:            "GET /users/1",
+			method:          "GET",
+			path:            "/users/1",
+			expectedCode:    200,
+			expectedResBody: `{"Name":"John"}`,
+		}, {
+			name:             "POST /users/1/posts/",
+			method:           "POST",
+			path:             "/users/1/posts/",
+			reqBody:          `{"title":"Hello"}`,
+			expectedCode:     201,
+			expectedLocation: "/users/1/posts/1",
+			expectedResBody:  `{"id":1,"user_id":1,"title":"Hello"}`,
+		}, {
+			name:             "POST /users/2/posts/",
+			method:           "POST",
+			path:             "/users/2/posts/",
+			reqBody:          `{"title":"Hi","user_id":1}`,
+			expectedCode:     201,
+			expectedLocation: "/users/2/posts/2",
+			expectedResBody:  `{"id":2,"user_id":2,"title":"Hi"}`,
+		}, {
+			name:            "GET /users/1/posts/",
+			method:          "GET",
+			path:            "/users/1/posts/",
+			expectedCode:    200,
+			expectedResBody: `[{"id":1,"user_id":1,"title":"Hello"}]`,
+		}, {
+			name:            "GET /users/2/posts/",
+			method:          "GET",
+			path:            "/users/2/posts/",
+			expectedCode:    200,
+			expectedResBody: `[{"id":2,"user_id":2,"title":"Hi"}]`,
+		}, {
+			name:            "GET /users/2/posts/?title=Hello of another user",
+			method:          "GET",
+			path:            "/users/2/posts/?title=Hello",
+			expectedCode:    200,
+			expectedResBody: `[]`,
+		}, {
+			name:            "GET /users/1/posts/1",
+			method:          "GET",
+			path:            "/users/1/posts/1",
+			expectedCode:    200,
+			expectedResBody: `{"id":1,"user_id":1,"title":"Hello"}`,
+		}, {
+			name:            "GET /users/1/posts/2 of another user",
+			method:          "GET",
+			path:            "/users/1/posts/2",
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		}, {
+			name:            "PUT /users/1/posts/2 of another user",
+			method:          "PUT",
+			path:            "/users/1/posts/2",
+			reqBody:         `{"title":"Hijacked"}`,
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		}, {
+			name:            "PATCH /users/1/posts/2 of another user",
+			method:          "PATCH",
+			path:            "/users/1/posts/2",
+			reqBody:         `{"title":"Hijacked"}`,
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		}, {
+			name:            "DELETE /users/1/posts/2 of another user",
+			method:          "DELETE",
+			path:            "/users/1/posts/2",
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		}, {
+			name:            "GET /users/2/posts/2 untouched",
+			method:          "GET",
+			path:            "/users/2/posts/2",
+			expectedCode:    200,
+			expectedResBody: `{"id":2,"user_id":2,"title":"Hi"}`,
+		}, {
+			name:            "PUT /users/2/posts/2 can't move it to another user",
+			method:          "PUT",
+			path:            "/users/2/posts/2",
+			reqBody:         `{"title":"Hi!","user_id":1}`,
+			expectedCode:    200,
+			expectedResBody: `{"id":2,"user_id":2,"title":"Hi!"}`,
+		}, {
+			name:            "GET /users/3/posts/ of a missing user",
+			method:          "GET",
+			path:            "/users/3/posts/",
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		}, {
+			name:            "POST /users/3/posts/ of a missing user",
+			method:          "POST",
+			path:            "/users/3/posts/",
+			reqBody:         `{"title":"Hello"}`,
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		}, {
+			name:            "GET /users/x/posts/",
+			method:          "GET",
+			path:            "/users/x/posts/",
+			expectedCode:    404,
+			expectedResBody: `{"error":"invalid key \"x\": invalid syntax"}`,
+		}, {
+			name:            "GET /users/1/comments/",
+			method:          "GET",
+			path:            "/users/1/comments/",
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.reqBody))
+			mux.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := test.expectedLocation, w.Header().Get("Location"); e != g {
+				t.Errorf("expected Location %s, got %s", e, g)
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
@@ -0,0 +1,578 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mash/ghost"
+)
+
+// Path is the well-known path where Serve serves the Document.
+const Path = "/openapi.json"
+
+// Document is an OpenAPI v3 document.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+type PathItem struct {
+	Get     *Operation `json:"get,omitempty"`
+	Put     *Operation `json:"put,omitempty"`
+	Post    *Operation `json:"post,omitempty"`
+	Delete  *Operation `json:"delete,omitempty"`
+	Patch   *Operation `json:"patch,omitempty"`
+	Head    *Operation `json:"head,omitempty"`
+	Options *Operation `json:"options,omitempty"`
+}
+
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]*Header   `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema is a subset of the OpenAPI v3 Schema Object.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// New returns a Document describing the REST API which ghost.New or ghost.NewS provides for R, Q and P.
+// The Resource schema honors json struct tags and the Query parameters honor schema struct tags like gorilla/schema does.
+// The Document describes all the operations, see NewForStore for those of a store.
+func New[R ghost.Resource, Q ghost.Query, P ghost.PKey](r R, q Q, p P, info Info) *Document {
+	return document(r, q, p, info, ghost.AllOperations, totalCountHeader)
+}
+
+// NewForStore is like New, but describes only the operations which store supports, see ghost.Operations,
+// and the X-Total-Count header only if store is a ghost.Counter.
+func NewForStore[R ghost.Resource, Q ghost.Query, P ghost.PKey](store ghost.Store[R, Q, P], info Info) *Document {
+	var (
+		r       R
+		q       Q
+		p       P
+		headers map[string]*Header
+	)
+	if ghost.Supports[ghost.Counter[Q]](store) {
+		headers = totalCountHeader
+	}
+	return document(r, q, p, info, ghost.Operations(store), headers)
+}
+
+// document returns the Document of the operations, whose HEAD / responds with the listHeaders.
+func document[R ghost.Resource, Q ghost.Query, P ghost.PKey](r R, q Q, p P, info Info, operations []ghost.Operation, listHeaders map[string]*Header) *Document {
+	g := generator{
+		schemas: map[string]*Schema{
+			"Error":      errorSchema,
+			"Problem":    problemSchema,
+			"FieldError": fieldErrorSchema,
+		},
+		names: map[reflect.Type]string{},
+	}
+	rt := reflect.TypeOf(r)
+	name := componentName(rt)
+	resource := g.schema(rt)
+	list := &Schema{Type: "array", Items: resource}
+	id := Parameter{Name: "id", In: "path", Required: true, Schema: g.schema(reflect.TypeOf(p))}
+
+	collection := &PathItem{Options: options(allow(operations, true))}
+	item := &PathItem{Options: options(allow(operations, false))}
+	for _, op := range operations {
+		switch op {
+		case ghost.OperationList:
+			collection.Get = &Operation{
+				OperationID: "list" + name,
+				Summary:     "List " + name,
+				Parameters:  append(g.parameters(reflect.TypeOf(q), ""), pageParameters...),
+				Responses:   responses(http.StatusOK, list),
+			}
+			collection.Head = &Operation{
+				OperationID: "count" + name,
+				Summary:     "Count " + name,
+				Parameters:  g.parameters(reflect.TypeOf(q), ""),
+				Responses:   headResponses(listHeaders),
+			}
+		case ghost.OperationCreate:
+			collection.Post = &Operation{
+				OperationID: "create" + name,
+				Summary:     "Create a " + name,
+				RequestBody: requestBody(resource),
+				Responses:   responses(http.StatusCreated, resource),
+			}
+		case ghost.OperationRead:
+			item.Get = &Operation{
+				OperationID: "read" + name,
+				Summary:     "Read a " + name,
+				Parameters:  []Parameter{id},
+				Responses:   responses(http.StatusOK, resource),
+			}
+			item.Head = &Operation{
+				OperationID: "head" + name,
+				Summary:     "Read the headers of a " + name,
+				Parameters:  []Parameter{id},
+				Responses:   headResponses(etagHeader),
+			}
+		case ghost.OperationUpdate:
+			item.Put = &Operation{
+				OperationID: "update" + name,
+				Summary:     "Update a " + name,
+				Parameters:  []Parameter{id},
+				RequestBody: requestBody(resource),
+				Responses:   responses(http.StatusOK, resource),
+			}
+		case ghost.OperationPatch:
+			item.Patch = &Operation{
+				OperationID: "patch" + name,
+				Summary:     "Patch a " + name,
+				Parameters:  []Parameter{id},
+				RequestBody: &RequestBody{
+					Required: true,
+					Content: map[string]MediaType{
+						ghost.MergePatchType: {Schema: &Schema{Type: "object"}},
+						ghost.JSONPatchType:  {Schema: &Schema{Type: "array", Items: &Schema{Type: "object"}}},
+					},
+				},
+				Responses: responses(http.StatusOK, resource),
+			}
+		case ghost.OperationDelete:
+			item.Delete = &Operation{
+				OperationID: "delete" + name,
+				Summary:     "Delete a " + name,
+				Parameters:  []Parameter{id},
+				Responses:   responses(http.StatusNoContent, nil),
+			}
+		}
+	}
+
+	return &Document{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths: map[string]*PathItem{
+			"/":     collection,
+			"/{id}": item,
+		},
+		Components: Components{
+			Schemas: g.schemas,
+		},
+	}
+}
+
+// Handler returns a http.Handler which serves doc as JSON.
+func Handler(doc *Document) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(doc)
+	})
+}
+
+// Serve returns a http.Handler which serves doc at Path and passes other requests to next.
+func Serve(next http.Handler, doc *Document) http.Handler {
+	h := Handler(doc)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == Path {
+			h.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// errorSchema describes ghost.Error, which implements json.Marshaler.
+// errors are the field errors of validator.FieldErrors, see ghost.Extender.
+var errorSchema = &Schema{
+	Type: "object",
+	Properties: map[string]*Schema{
+		"error":  {Type: "string"},
+		"errors": {Type: "array", Items: ref("FieldError")},
+	},
+	Required: []string{"error"},
+}
+
+// problemSchema describes ghost.Problem, see ghost.ProblemErrorHandler.
+var problemSchema = &Schema{
+	Type: "object",
+	Properties: map[string]*Schema{
+		"type":     {Type: "string"},
+		"title":    {Type: "string"},
+		"status":   {Type: "integer", Format: "int64"},
+		"detail":   {Type: "string"},
+		"instance": {Type: "string"},
+		"errors":   {Type: "array", Items: ref("FieldError")},
+	},
+	Required: []string{"type", "title", "status"},
+}
+
+// fieldErrorSchema describes validator.FieldError.
+var fieldErrorSchema = &Schema{
+	Type: "object",
+	Properties: map[string]*Schema{
+		"field":     {Type: "string"},
+		"json_path": {Type: "string"},
+		"tag":       {Type: "string"},
+		"param":     {Type: "string"},
+		"message":   {Type: "string"},
+	},
+	Required: []string{"field", "json_path", "tag", "message"},
+}
+
+var (
+	etagHeader       = map[string]*Header{"ETag": {Schema: &Schema{Type: "string"}}}
+	totalCountHeader = map[string]*Header{"X-Total-Count": {Description: "The number of resources which match the query, if the store is a ghost.Counter", Schema: &Schema{Type: "integer", Format: "int64"}}}
+)
+
+// pageParameters are the query parameters which are parsed into a ghost.Page.
+var pageParameters = []Parameter{
+	{Name: "limit", In: "query", Schema: &Schema{Type: "integer", Format: "int64"}},
+	{Name: "offset", In: "query", Schema: &Schema{Type: "integer", Format: "int64"}},
+	{Name: "cursor", In: "query", Schema: &Schema{Type: "string"}},
+}
+
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func requestBody(s *Schema) *RequestBody {
+	return &RequestBody{
+		Required: true,
+		Content: map[string]MediaType{
+			"application/json": {Schema: s},
+		},
+	}
+}
+
+// headResponses are the responses of HEAD, which have the headers of GET but no content.
+func headResponses(headers map[string]*Header) map[string]*Response {
+	res := responses(http.StatusOK, nil)
+	res["200"].Headers = headers
+	return res
+}
+
+// allow returns the methods which Ghost allows for the collection, or a resource, with the operations.
+func allow(operations []ghost.Operation, collection bool) []string {
+	methods := []string{http.MethodOptions}
+	for _, op := range operations {
+		switch {
+		case op == ghost.OperationList && collection, op == ghost.OperationRead && !collection:
+			methods = append(methods, http.MethodGet, http.MethodHead)
+		case op == ghost.OperationCreate && collection:
+			methods = append(methods, http.MethodPost)
+		case op == ghost.OperationUpdate && !collection:
+			methods = append(methods, http.MethodPut)
+		case op == ghost.OperationPatch && !collection:
+			methods = append(methods, http.MethodPatch)
+		case op == ghost.OperationDelete && !collection:
+			methods = append(methods, http.MethodDelete)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// options returns the OPTIONS Operation, which responds with the allowed methods.
+func options(methods []string) *Operation {
+	return &Operation{
+		Summary: "Allowed methods: " + strings.Join(methods, ", "),
+		Responses: map[string]*Response{
+			"204": {
+				Description: http.StatusText(http.StatusNoContent),
+				Headers:     map[string]*Header{"Allow": {Schema: &Schema{Type: "string"}}},
+			},
+		},
+	}
+}
+
+func responses(code int, s *Schema) map[string]*Response {
+	res := &Response{
+		Description: http.StatusText(code),
+	}
+	if s != nil {
+		res.Content = map[string]MediaType{
+			"application/json": {Schema: s},
+		}
+	}
+	return map[string]*Response{
+		strconv.Itoa(code): res,
+		"default": {
+			Description: "Error",
+			Content: map[string]MediaType{
+				"application/json": {Schema: ref("Error")},
+				ghost.ProblemType:  {Schema: ref("Problem")},
+			},
+		},
+	}
+}
+
+type generator struct {
+	schemas map[string]*Schema
+	// names are the names of the components of the named struct types
+	names map[reflect.Type]string
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schema returns the Schema of t. Named struct types are added to the components and referenced.
+func (g generator) schema(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+	s := g.typeSchema(t)
+	if nullable && s.Ref == "" {
+		s.Nullable = true
+	}
+	return s
+}
+
+func (g generator) typeSchema(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType):
+		// we can't know what a custom marshaler produces
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json encodes []byte as a base64 string
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.name(t)
+			// reserve the name first to support recursive types
+			g.names[t] = name
+			g.schemas[name] = &Schema{}
+			*g.schemas[name] = *g.structSchema(t)
+		}
+		return ref(name)
+	default:
+		return &Schema{}
+	}
+}
+
+// name returns a name for the component of t which no other component has,
+// such as User2 if there already is a User, or an Error besides the built-in one.
+func (g generator) name(t reflect.Type) string {
+	base := componentName(t)
+	name := base
+	for i := 2; g.schemas[name] != nil; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	return name
+}
+
+var (
+	packagePath = regexp.MustCompile(`[\w.\-]*/`)
+	packageName = regexp.MustCompile(`\w+\.`)
+	invalidName = regexp.MustCompile(`[^A-Za-z0-9._\-]+`)
+)
+
+// componentName returns the name of t without the packages of its type arguments, in the characters which component names allow,
+// such as Page_User for Page[example.com/users.User].
+func componentName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	name := packageName.ReplaceAllString(packagePath.ReplaceAllString(t.Name(), ""), "")
+	return strings.Trim(invalidName.ReplaceAllString(name, "_"), "_")
+}
+
+func (g generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{},
+	}
+	g.fields(s, t)
+	return s
+}
+
+// fields adds the fields of t to s the same way encoding/json would encode them.
+func (g generator) fields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts := tag(f.Tag.Get("json"))
+		if name == "-" && opts == "" {
+			continue
+		}
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(s, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fs := g.schema(f.Type)
+		if hasOption(opts, "string") {
+			fs = &Schema{Type: "string"}
+		}
+		s.Properties[name] = fs
+		if required(f.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// parameters returns the query parameters which gorilla/schema maps onto t.
+func (g generator) parameters(t reflect.Type, prefix string) []Parameter {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var params []Parameter
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts := tag(f.Tag.Get("schema"))
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft != timeType {
+			if f.Anonymous {
+				params = append(params, g.parameters(ft, prefix)...)
+			} else {
+				params = append(params, g.parameters(ft, prefix+name+".")...)
+			}
+			continue
+		}
+		params = append(params, Parameter{
+			Name:     prefix + name,
+			In:       "query",
+			Required: hasOption(opts, "required"),
+			Schema:   g.schema(f.Type),
+		})
+	}
+	return params
+}
+
+func tag(t string) (name, opts string) {
+	name, opts, _ = strings.Cut(t, ",")
+	return name, opts
+}
+
+// hasOption reports whether the comma separated opts have the option.
+func hasOption(opts, option string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// required reports whether the rules of the validate struct tag require the field.
+// Rules such as required_if are conditional, and the rules after dive apply to the elements.
+func required(validate string) bool {
+	for _, rule := range strings.Split(validate, ",") {
+		switch rule {
+		case "required":
+			return true
+		case "dive":
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mash/ghost"
+	"github.com/mash/ghost/openapi"
+)
+
+type Address struct {
+	City string `json:"city"`
+}
+
+type User struct {
+	Name      string    `json:"name" validate:"required"`
+	Age       int       `json:"age,omitempty"`
+	Tags      []string  `json:"tags"`
+	Address   *Address  `json:"address"`
+	CreatedAt time.Time `json:"created_at"`
+	Secret    string    `json:"-"`
+}
+
+type SearchQuery struct {
+	Name  string `schema:"name"`
+	MinID uint64 `schema:"min_id,required"`
+	Skip  string `schema:"-"`
+}
+
+func TestNew(t *testing.T) {
+	doc := openapi.New(User{}, SearchQuery{}, uint64(0), openapi.Info{Title: "users", Version: "1.0.0"})
+
+	if diff := cmp.Diff(map[string]*openapi.Schema{
+		"Error": {
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"error":  {Type: "string"},
+				"errors": {Type: "array", Items: &openapi.Schema{Ref: "#/components/schemas/FieldError"}},
+			},
+			Required: []string{"error"},
+		},
+		"Problem": {
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"type":     {Type: "string"},
+				"title":    {Type: "string"},
+				"status":   {Type: "integer", Format: "int64"},
+				"detail":   {Type: "string"},
+				"instance": {Type: "string"},
+				"errors":   {Type: "array", Items: &openapi.Schema{Ref: "#/components/schemas/FieldError"}},
+			},
+			Required: []string{"type", "title", "status"},
+		},
+		"FieldError": {
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"field":     {Type: "string"},
+				"json_path": {Type: "string"},
+				"tag":       {Type: "string"},
+				"param":     {Type: "string"},
+				"message":   {Type: "string"},
+			},
+			Required: []string{"field", "json_path", "tag", "message"},
+		},
+		"Address": {
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"city": {Type: "string"},
+			},
+		},
+		"User": {
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"name":       {Type: "string"},
+				"age":        {Type: "integer", Format: "int64"},
+				"tags":       {Type: "array", Items: &openapi.Schema{Type: "string"}},
+				"address":    {Ref: "#/components/schemas/Address"},
+				"created_at": {Type: "string", Format: "date-time"},
+			},
+			Required: []string{"name"},
+		},
+	}, doc.Components.Schemas); diff != "" {
+		t.Errorf("unexpected schemas (-want +got):\n%s", diff)
+	}
+
+	if diff := cmp.Diff([]openapi.Parameter{
+		{Name: "name", In: "query", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "min_id", In: "query", Required: true, Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+		{Name: "limit", In: "query", Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+		{Name: "offset", In: "query", Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+		{Name: "cursor", In: "query", Schema: &openapi.Schema{Type: "string"}},
+	}, doc.Paths["/"].Get.Parameters); diff != "" {
+		t.Errorf("unexpected parameters (-want +got):\n%s", diff)
+	}
+
+	if diff := cmp.Diff([]openapi.Parameter{
+		{Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+	}, doc.Paths["/{id}"].Delete.Parameters); diff != "" {
+		t.Errorf("unexpected parameters (-want +got):\n%s", diff)
+	}
+
+	if diff := cmp.Diff(map[string]openapi.MediaType{
+		"application/json":         {Schema: &openapi.Schema{Ref: "#/components/schemas/Error"}},
+		"application/problem+json": {Schema: &openapi.Schema{Ref: "#/components/schemas/Problem"}},
+	}, doc.Paths["/{id}"].Get.Responses["default"].Content); diff != "" {
+		t.Errorf("unexpected error content (-want +got):\n%s", diff)
+	}
+
+	if _, ok := doc.Paths["/"].Head.Responses["200"].Headers["X-Total-Count"]; !ok {
+		t.Errorf("expected HEAD / to have X-Total-Count")
+	}
+	if _, ok := doc.Paths["/{id}"].Head.Responses["200"].Headers["ETag"]; !ok {
+		t.Errorf("expected HEAD /{id} to have ETag")
+	}
+	for _, path := range []string{"/", "/{id}"} {
+		if _, ok := doc.Paths[path].Options.Responses["204"].Headers["Allow"]; !ok {
+			t.Errorf("expected OPTIONS %s to have Allow", path)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	store := ghost.NewMapStore(User{}, SearchQuery{}, uint64(0))
+	doc := openapi.New(User{}, SearchQuery{}, uint64(0), openapi.Info{Title: "users", Version: "1.0.0"})
+	h := openapi.Serve(ghost.New(store), doc)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", openapi.Path, nil))
+	if e, g := 200, w.Code; e != g {
+		t.Fatalf("expected %d, got %d", e, g)
+	}
+	var got openapi.Document
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode json body: %v", err)
+	}
+	if diff := cmp.Diff(*doc, got); diff != "" {
+		t.Errorf("unexpected document (-want +got):\n%s", diff)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/?min_id=1", nil))
+	if e, g := 200, w.Code; e != g {
+		t.Errorf("expected %d, got %d", e, g)
+	}
+}
+
+type Error struct {
+	Code int `json:"code"`
+}
+
+type Page[T any] struct {
+	Items []T `json:"items"`
+}
+
+type Report struct {
+	Title    string          `json:"title" validate:"required_if=Draft false"`
+	Draft    bool            `json:"draft"`
+	Owner    string          `json:"owner" validate:"omitempty,required"`
+	Tags     []string        `json:"tags" validate:"dive,required"`
+	Err      Error           `json:"err"`
+	Page     Page[Address]   `json:"page"`
+	Pointers *Page[*Address] `json:"pointers"`
+}
+
+func TestNewComponents(t *testing.T) {
+	doc := openapi.New(Report{}, SearchQuery{}, uint64(0), openapi.Info{Title: "reports", Version: "1.0.0"})
+
+	if diff := cmp.Diff(&openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"title":    {Type: "string"},
+			"draft":    {Type: "boolean"},
+			"owner":    {Type: "string"},
+			"tags":     {Type: "array", Items: &openapi.Schema{Type: "string"}},
+			"err":      {Ref: "#/components/schemas/Error2"},
+			"page":     {Ref: "#/components/schemas/Page_Address"},
+			"pointers": {Ref: "#/components/schemas/Page_Address2"},
+		},
+		Required: []string{"owner"},
+	}, doc.Components.Schemas["Report"]); diff != "" {
+		t.Errorf("unexpected schema (-want +got):\n%s", diff)
+	}
+	if e, g := "integer", doc.Components.Schemas["Error2"].Properties["code"].Type; e != g {
+		t.Errorf("expected %s, got %s", e, g)
+	}
+	if e, g := "string", doc.Components.Schemas["Error"].Properties["error"].Type; e != g {
+		t.Errorf("expected the built-in Error to be kept, got %s", g)
+	}
+}
+
+// readOnlyStore supports reading and listing only, and isn't a ghost.Counter.
+type readOnlyStore struct {
+	ghost.Store[User, SearchQuery, uint64]
+}
+
+func (s readOnlyStore) Operations() []ghost.Operation {
+	return []ghost.Operation{ghost.OperationRead, ghost.OperationList}
+}
+
+func TestNewForStore(t *testing.T) {
+	store := ghost.NewMapStore(User{}, SearchQuery{}, uint64(0))
+	info := openapi.Info{Title: "users", Version: "1.0.0"}
+
+	doc := openapi.NewForStore(store, info)
+	if _, ok := doc.Paths["/"].Head.Responses["200"].Headers["X-Total-Count"]; !ok {
+		t.Errorf("expected HEAD / of a ghost.Counter to have X-Total-Count")
+	}
+	if doc.Paths["/"].Post == nil || doc.Paths["/{id}"].Delete == nil {
+		t.Errorf("expected all the operations")
+	}
+
+	doc = openapi.NewForStore[User, SearchQuery, uint64](readOnlyStore{store}, info)
+	collection, item := doc.Paths["/"], doc.Paths["/{id}"]
+	if collection.Get == nil || collection.Head == nil || item.Get == nil || item.Head == nil {
+		t.Errorf("expected GET and HEAD")
+	}
+	if collection.Post != nil || item.Put != nil || item.Patch != nil || item.Delete != nil {
+		t.Errorf("expected no POST, PUT, PATCH and DELETE")
+	}
+	if _, ok := collection.Head.Responses["200"].Headers["X-Total-Count"]; ok {
+		t.Errorf("expected HEAD / to have no X-Total-Count")
+	}
+	for path, e := range map[string]string{"/": "Allowed methods: GET, HEAD, OPTIONS", "/{id}": "Allowed methods: GET, HEAD, OPTIONS"} {
+		if g := doc.Paths[path].Options.Summary; e != g {
+			t.Errorf("OPTIONS %s: expected %s, got %s", path, e, g)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package ghost
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Page is a page request.
+// It is parsed from the limit, offset and cursor URL query parameters, alongside the Query.
+// A zero Limit means no limit.
+type Page struct {
+	Limit  int
+	Offset int
+	Cursor string
+}
+
+// Paging limits the pages which List responds with, see WithPaging.
+type Paging struct {
+	// DefaultLimit is the Limit of the requests without one. Zero means no limit.
+	DefaultLimit int
+	// MaxLimit lowers larger Limits, and no limit, to it. Zero means no maximum.
+	MaxLimit int
+}
+
+// limit applies the limits to page.
+func (p Paging) limit(page Page) Page {
+	if page.Limit == 0 {
+		page.Limit = p.DefaultLimit
+	}
+	if p.MaxLimit > 0 && (page.Limit == 0 || page.Limit > p.MaxLimit) {
+		page.Limit = p.MaxLimit
+	}
+	return page
+}
+
+// PageParams are the URL query parameters which are parsed into a Page instead of the Query.
+var PageParams = []string{"limit", "offset", "cursor"}
+
+// PagedStore is implemented by stores which can list a page of resources.
+// next is the opaque cursor of the following page, or empty if there are no more resources.
+type PagedStore[R Resource, Q Query] interface {
+	ListPage(ctx context.Context, q *Q, page Page) (rs []R, next string, err error)
+}
+
+var ErrInvalidCursor = Error{
+	Code: http.StatusBadRequest,
+	Err:  errors.New("invalid cursor"),
+}
+
+// EncodeCursor encodes v into an opaque cursor.
+func EncodeCursor(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeCursor decodes the cursor which EncodeCursor returned into v.
+func DecodeCursor(cursor string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// PageOf returns the page of rs, for stores which can't paginate natively.
+// The cursors are offsets into rs.
+func PageOf[R Resource](rs []R, page Page) ([]R, string, error) {
+	start := page.Offset
+	if page.Cursor != "" {
+		var offset int
+		if err := DecodeCursor(page.Cursor, &offset); err != nil || offset < 0 {
+			return nil, "", ErrInvalidCursor
+		}
+		start += offset
+	}
+	if start >= len(rs) {
+		return []R{}, "", nil
+	}
+	rs = rs[start:]
+	if page.Limit == 0 || page.Limit >= len(rs) {
+		return rs, "", nil
+	}
+	next, err := EncodeCursor(start + page.Limit)
+	return rs[:page.Limit], next, err
+}
+
+func parsePage(r *http.Request) (Page, error) {
+	var page Page
+	values := r.URL.Query()
+	for _, p := range []struct {
+		name string
+		v    *int
+	}{
+		{"limit", &page.Limit},
+		{"offset", &page.Offset},
+	} {
+		s := values.Get(p.name)
+		if s == "" {
+			continue
+		}
+		i, err := strconv.Atoi(s)
+		if err != nil || i < 0 {
+			return page, Error{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid %s %q", p.name, s)}
+		}
+		*p.v = i
+	}
+	page.Cursor = values.Get("cursor")
+	return page, nil
+}
+
+// nextLink returns a RFC 8288 Link header value referring to the page after the cursor.
+func nextLink(r *http.Request, page Page, cursor string) string {
+	values := r.URL.Query()
+	values.Del("offset")
+	values.Set("cursor", cursor)
+	if page.Limit > 0 {
+		values.Set("limit", strconv.Itoa(page.Limit))
+	}
+	return fmt.Sprintf(`<%s?%s>; rel="next"`, requestPath(r), values.Encode())
+}
@@ -0,0 +1,521 @@
+package ghost
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MergePatchType is the media type of JSON Merge Patch (RFC 7396) documents.
+	MergePatchType = "application/merge-patch+json"
+	// JSONPatchType is the media type of JSON Patch (RFC 6902) documents.
+	JSONPatchType = "application/json-patch+json"
+)
+
+// jsonName returns the name in the json tag of f, and whether encoding/json skips f.
+// The tag "-" skips the field, but "-," names it "-".
+func jsonName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	return name, false
+}
+
+// unmarshalJSON decodes the JSON value b into v, with numbers as json.Numbers,
+// so that integers beyond the precision of float64 survive being decoded into any.
+func unmarshalJSON(b []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid data after the JSON value")
+	}
+	return nil
+}
+
+// jsonEqual reports whether the JSON values a and b, decoded by unmarshalJSON, are equal.
+// Numbers are compared by value, so that 1 equals 1.0.
+func jsonEqual(a, b any) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, okx := new(big.Rat).SetString(a.String())
+		y, oky := new(big.Rat).SetString(b.String())
+		return okx && oky && x.Cmp(y) == 0
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			w, ok := b[k]
+			if !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// mergePatch applies the JSON Merge Patch (RFC 7396) patch to r.
+// Nested objects are merged recursively, and members which are null are removed, which zeroes their fields.
+func mergePatch[R Resource](r R, patch []byte) (R, error) {
+	var p any
+	if err := unmarshalJSON(patch, &p); err != nil {
+		return r, err
+	}
+	doc, err := toJSON(r)
+	if err != nil {
+		return r, err
+	}
+	return fromJSON(r, mergeValue(doc, p), false)
+}
+
+// toJSON returns the JSON value which r encodes to.
+func toJSON[R Resource](r R) (any, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	err = unmarshalJSON(b, &doc)
+	return doc, err
+}
+
+// fromJSON decodes the JSON value doc onto a copy of r whose fields which encoding/json encodes are zeroed,
+// so that the fields which it ignores, such as those tagged with "-", keep their values.
+// If disallowUnknownFields, members which don't match any field are errors.
+func fromJSON[R Resource](r R, doc any, disallowUnknownFields bool) (R, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return r, err
+	}
+	rr := r
+	zeroJSONFields(reflect.ValueOf(&rr).Elem())
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if disallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	err = dec.Decode(&rr)
+	return rr, err
+}
+
+// zeroJSONFields sets the fields of v which encoding/json encodes to their zero values, or v itself if it isn't a struct.
+// Embedded struct pointers are copied first, so that the structs they point to aren't changed.
+func zeroJSONFields(v reflect.Value) {
+	if v.Kind() != reflect.Struct {
+		v.Set(reflect.Zero(v.Type()))
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, skip := jsonName(f)
+		if skip {
+			continue
+		}
+		fv := v.Field(i)
+		if f.Anonymous && name == "" {
+			switch {
+			case f.Type.Kind() == reflect.Struct:
+				zeroJSONFields(fv)
+				continue
+			case f.Type.Kind() == reflect.Pointer && f.Type.Elem().Kind() == reflect.Struct:
+				if fv.IsNil() || !fv.CanSet() {
+					continue
+				}
+				c := reflect.New(f.Type.Elem())
+				c.Elem().Set(fv.Elem())
+				fv.Set(c)
+				zeroJSONFields(c.Elem())
+				continue
+			}
+		}
+		if f.IsExported() && fv.CanSet() {
+			fv.Set(reflect.Zero(f.Type))
+		}
+	}
+}
+
+func mergeValue(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergeValue(t[k], v)
+	}
+	return t
+}
+
+// jsonPatch applies the JSON Patch (RFC 6902) patch to r.
+// A malformed patch is a 400 Bad Request, an operation on a nonexistent path is a 422 Unprocessable Entity
+// and a failed test operation is a 409 Conflict.
+// The result is decoded like mergePatch does, and if disallowUnknownFields, members added which don't match any field
+// are a 422 Unprocessable Entity too, instead of being dropped.
+func jsonPatch[R Resource](r R, patch []byte, disallowUnknownFields bool) (R, error) {
+	var ops []patchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return r, bodyError(err)
+	}
+	doc, err := toJSON(r)
+	if err != nil {
+		return r, err
+	}
+	for i, op := range ops {
+		if doc, err = op.apply(doc); err != nil {
+			var e Error
+			if errors.As(err, &e) {
+				return r, Error{Code: e.Code, Err: fmt.Errorf("operation %d (%s): %w", i, op.Op, e.Err)}
+			}
+			return r, err
+		}
+	}
+	rr, err := fromJSON(r, doc, disallowUnknownFields)
+	if err != nil {
+		return r, Error{Code: http.StatusUnprocessableEntity, Err: err}
+	}
+	return rr, nil
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (op patchOperation) apply(doc any) (any, error) {
+	if op.Path == nil {
+		return nil, malformedPatch("missing path")
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, malformedPatch("missing value")
+		}
+		var v any
+		if err := unmarshalJSON(op.Value, &v); err != nil {
+			return nil, malformedPatch(err.Error())
+		}
+		switch op.Op {
+		case "add":
+			return addValue(doc, path, v)
+		case "replace":
+			return replaceValue(doc, path, v)
+		default:
+			cur, err := getValue(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !jsonEqual(cur, v) {
+				return nil, Error{Code: http.StatusConflict, Err: fmt.Errorf("test failed at %q", *op.Path)}
+			}
+			return doc, nil
+		}
+	case "remove":
+		return removeValue(doc, path)
+	case "move", "copy":
+		if op.From == nil {
+			return nil, malformedPatch("missing from")
+		}
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+		v, err := getValue(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			// copy deeply, the copied value must not share maps and slices with the original
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			if err := unmarshalJSON(b, &v); err != nil {
+				return nil, err
+			}
+			return addValue(doc, path, v)
+		}
+		if *op.Path == *op.From {
+			return doc, nil
+		}
+		if strings.HasPrefix(*op.Path, *op.From+"/") {
+			return nil, malformedPatch("cannot move a value into one of its children")
+		}
+		if doc, err = removeValue(doc, from); err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, v)
+	default:
+		return nil, malformedPatch(fmt.Sprintf("unknown op %q", op.Op))
+	}
+}
+
+func malformedPatch(msg string) Error {
+	return Error{Code: http.StatusBadRequest, Err: errors.New(msg)}
+}
+
+func unprocessablePatch(format string, a ...any) Error {
+	return Error{Code: http.StatusUnprocessableEntity, Err: fmt.Errorf(format, a...)}
+}
+
+// parsePointer parses a JSON Pointer (RFC 6901) into its reference tokens.
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if p[0] != '/' {
+		return nil, malformedPatch(fmt.Sprintf("invalid JSON pointer %q", p))
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses an array index token which must be less than max.
+func arrayIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || strconv.Itoa(i) != token {
+		return 0, unprocessablePatch("invalid array index %q", token)
+	}
+	if i >= max {
+		return 0, unprocessablePatch("array index %d out of bounds", i)
+	}
+	return i, nil
+}
+
+func getValue(doc any, path []string) (any, error) {
+	for _, t := range path {
+		switch n := doc.(type) {
+		case map[string]any:
+			v, ok := n[t]
+			if !ok {
+				return nil, unprocessablePatch("member %q not found", t)
+			}
+			doc = v
+		case []any:
+			i, err := arrayIndex(t, len(n))
+			if err != nil {
+				return nil, err
+			}
+			doc = n[i]
+		default:
+			return nil, unprocessablePatch("cannot traverse into %q", t)
+		}
+	}
+	return doc, nil
+}
+
+// updateParent applies f to the container which holds the value at path, replacing the container with what f returns.
+func updateParent(doc any, path []string, f func(container any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return f(doc, path[0])
+	}
+	switch n := doc.(type) {
+	case map[string]any:
+		v, ok := n[path[0]]
+		if !ok {
+			return nil, unprocessablePatch("member %q not found", path[0])
+		}
+		v, err := updateParent(v, path[1:], f)
+		if err != nil {
+			return nil, err
+		}
+		n[path[0]] = v
+		return n, nil
+	case []any:
+		i, err := arrayIndex(path[0], len(n))
+		if err != nil {
+			return nil, err
+		}
+		v, err := updateParent(n[i], path[1:], f)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = v
+		return n, nil
+	default:
+		return nil, unprocessablePatch("cannot traverse into %q", path[0])
+	}
+}
+
+func addValue(doc any, path []string, v any) (any, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	return updateParent(doc, path, func(c any, t string) (any, error) {
+		switch n := c.(type) {
+		case map[string]any:
+			n[t] = v
+			return n, nil
+		case []any:
+			if t == "-" {
+				return append(n, v), nil
+			}
+			i, err := arrayIndex(t, len(n)+1)
+			if err != nil {
+				return nil, err
+			}
+			n = append(n, nil)
+			copy(n[i+1:], n[i:])
+			n[i] = v
+			return n, nil
+		default:
+			return nil, unprocessablePatch("cannot traverse into %q", t)
+		}
+	})
+}
+
+func removeValue(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, unprocessablePatch("cannot remove the whole document")
+	}
+	return updateParent(doc, path, func(c any, t string) (any, error) {
+		switch n := c.(type) {
+		case map[string]any:
+			if _, ok := n[t]; !ok {
+				return nil, unprocessablePatch("member %q not found", t)
+			}
+			delete(n, t)
+			return n, nil
+		case []any:
+			i, err := arrayIndex(t, len(n))
+			if err != nil {
+				return nil, err
+			}
+			return append(n[:i], n[i+1:]...), nil
+		default:
+			return nil, unprocessablePatch("cannot traverse into %q", t)
+		}
+	})
+}
+
+func replaceValue(doc any, path []string, v any) (any, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	return updateParent(doc, path, func(c any, t string) (any, error) {
+		switch n := c.(type) {
+		case map[string]any:
+			if _, ok := n[t]; !ok {
+				return nil, unprocessablePatch("member %q not found", t)
+			}
+			n[t] = v
+			return n, nil
+		case []any:
+			i, err := arrayIndex(t, len(n))
+			if err != nil {
+				return nil, err
+			}
+			n[i] = v
+			return n, nil
+		default:
+			return nil, unprocessablePatch("cannot traverse into %q", t)
+		}
+	})
+}
+
+// patchFields returns the Go field names of R which the JSON object keys refer to,
+// matching them the same way encoding/json does.
+func patchFields[R Resource](keys map[string]json.RawMessage) []string {
+	var r R
+	names := map[string]string{}
+	jsonFields(reflect.TypeOf(r), names)
+
+	var fields []string
+	for k := range keys {
+		if f, ok := names[k]; ok {
+			fields = append(fields, f)
+			continue
+		}
+		for n, f := range names {
+			if strings.EqualFold(n, k) {
+				fields = append(fields, f)
+				break
+			}
+		}
+	}
+	return fields
+}
+
+// jsonFields maps the JSON names of the fields of t to their Go names.
+// Fields of embedded structs are flattened unless shadowed, as in encoding/json.
+func jsonFields(t reflect.Type, names map[string]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, skip := jsonName(f)
+		if skip {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			embedded = append(embedded, f.Type)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names[name] = f.Name
+	}
+	for _, e := range embedded {
+		m := map[string]string{}
+		jsonFields(e, m)
+		for k, v := range m {
+			if _, ok := names[k]; !ok {
+				names[k] = v
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+//go:build go1.22
+
+package ghost
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Wildcard is the name of the path wildcard of the PKey in the patterns registered by Ghost.Handle.
+const Wildcard = "id"
+
+// PathValueIdentifier is an Identifier which parses the path wildcard Name of a http.ServeMux pattern.
+// Like PathIdentifier, keys which can't be parsed are 404 Not Found Errors with a PathKeyError.
+type PathValueIdentifier[P PKey] struct {
+	Name  string
+	Parse PathIdentifier[P]
+}
+
+func (pi PathValueIdentifier[P]) PKey(r *http.Request) (P, error) {
+	v := r.PathValue(pi.Name)
+	if v == "" {
+		var p P
+		return p, ErrNotFound
+	}
+	return pi.Parse.parse(v)
+}
+
+// Handle registers the operations of g on mux with a pattern each, so that mux responds with 405 Method Not Allowed
+// and the Allow header to other methods, and the Ghost resources can be mixed with other routes:
+//
+//	GET     prefix/{$}
+//	POST    prefix/{$}
+//	GET     prefix/{id}
+//	PUT     prefix/{id}
+//	PATCH   prefix/{id}
+//	DELETE  prefix/{id}
+//	OPTIONS prefix/{$}
+//	OPTIONS prefix/{id}
+//
+// Operations which the Server doesn't support, see Operator, aren't registered.
+// HEAD is handled by the GET patterns, or by HEAD patterns if the Server is a HeadServer.
+// The Identifier of the Server of g should be a PathValueIdentifier of Wildcard, see HandleMux.
+// The Mux of g isn't used.
+//
+// Handle is built with Go 1.22 or later, whose http.ServeMux supports the patterns if the main module declares go 1.22 or later,
+// or if GODEBUG has httpmuxgo121=0.
+func (g Ghost[R, Q, P]) Handle(mux *http.ServeMux, prefix string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	collection, item := prefix+"/{$}", prefix+"/{"+Wildcard+"}"
+	ops := operations(g.Server)
+	for _, op := range []struct {
+		operation Operation
+		pattern   string
+		handler   Handler
+	}{
+		{OperationList, "GET " + collection, g.Server.List},
+		{OperationCreate, "POST " + collection, g.Server.Create},
+		{OperationRead, "GET " + item, g.Server.Read},
+		{OperationUpdate, "PUT " + item, g.Server.Update},
+		{OperationPatch, "PATCH " + item, g.Server.Patch},
+		{OperationDelete, "DELETE " + item, g.Server.Delete},
+	} {
+		if containsOperation(ops, op.operation) {
+			g.handle(mux, op.pattern, op.handler)
+		}
+	}
+	if h, ok := g.Server.(HeadServer); ok {
+		if containsOperation(ops, OperationList) {
+			g.handle(mux, "HEAD "+collection, h.Head)
+		}
+		if containsOperation(ops, OperationRead) {
+			g.handle(mux, "HEAD "+item, h.Head)
+		}
+	}
+	g.handle(mux, "OPTIONS "+collection, options(allow(ops, true)))
+	g.handle(mux, "OPTIONS "+item, options(allow(ops, false)))
+}
+
+func (g Ghost[R, Q, P]) handle(mux *http.ServeMux, pattern string, op Handler) {
+	mux.Handle(pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := op(w, r); err != nil {
+			g.ErrorHandler(err).ServeHTTP(w, r)
+		}
+	}))
+}
+
+// HandleMux registers the resources of store on mux under prefix, like New, with Ghost.Handle.
+// HandleMux requires PKey to be an integer.
+func HandleMux[R Resource, Q Query, P PUintKey](mux *http.ServeMux, prefix string, store Store[R, Q, P]) {
+	Ghost[R, Q, P]{
+		Server:       NewServer[R, Q, P](NewHookStore(store), JSON[R]{}, PathValueIdentifier[P]{Name: Wildcard, Parse: UintPath[P]}, NewQueryParser[Q]()),
+		ErrorHandler: DefaultErrorHandler(JSON[Error]{}),
+	}.Handle(mux, prefix)
+}
+
+// HandleMuxS registers the resources of store on mux under prefix, like NewS, with Ghost.Handle.
+// HandleMuxS requires PKey to be a string.
+func HandleMuxS[R Resource, Q Query, P PStrKey](mux *http.ServeMux, prefix string, store Store[R, Q, P]) {
+	Ghost[R, Q, P]{
+		Server:       NewServer[R, Q, P](NewHookStore(store), JSON[R]{}, PathValueIdentifier[P]{Name: Wildcard, Parse: StrPath[P]}, NewQueryParser[Q]()),
+		ErrorHandler: DefaultErrorHandler(JSON[Error]{}),
+	}.Handle(mux, prefix)
+}
@@ -0,0 +1,193 @@
+//go:build go1.22
+
+// the module declares go 1.18, which defaults to the patterns of Go 1.21
+//
+//go:debug httpmuxgo121=0
+
+package ghost_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mash/ghost"
+)
+
+func TestHandleMux(t *testing.T) {
+	mux := http.NewServeMux()
+	ghost.HandleMux(mux, "/users", ghost.NewMapStore(User{}, SearchQuery{}, uint64(0)))
+	ghost.HandleMuxS(mux, "/items/", ghost.NewMapStrStore(StrItem{}, SearchQuery{}, ""))
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	tests := []struct {
+		name, method, path, reqBody string
+		expectedCode                int
+		expectedAllow               string
+		expectedLocation            string
+		expectedResBody             string
+	}{
+		{
+			name:            "POST /users/",
+			method:          "POST",
+			path:            "/users/",
+			reqBody:         `{"Name":"John"}`,
+			expectedCode:    201,
+			expectedResBody: `{"Name":"John"}`,
+		}, {
+			name:            "GET /users/",
+			method:          "GET",
+			path:            "/users/",
+			expectedCode:    200,
+			expectedResBody: `[{"Name":"John"}]`,
+		}, {
+			name:            "GET /users/1",
+			method:          "GET",
+			path:            "/users/1",
+			expectedCode:    200,
+			expectedResBody: `{"Name":"John"}`,
+		}, {
+			name:            "PATCH /users/1",
+			method:          "PATCH",
+			path:            "/users/1",
+			reqBody:         `{"Name":"Bob"}`,
+			expectedCode:    200,
+			expectedResBody: `{"Name":"Bob"}`,
+		}, {
+			name:            "GET /users/x",
+			method:          "GET",
+			path:            "/users/x",
+			expectedCode:    404,
+			expectedResBody: `{"error":"invalid key \"x\": invalid syntax"}`,
+		}, {
+			name:         "DELETE /users/1",
+			method:       "DELETE",
+			path:         "/users/1",
+			expectedCode: 204,
+		}, {
+			name:            "GET /users/1 deleted",
+			method:          "GET",
+			path:            "/users/1",
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		}, {
+			name:            "DELETE /users/",
+			method:          "DELETE",
+			path:            "/users/",
+			expectedCode:    405,
+			expectedAllow:   "GET, HEAD, OPTIONS, POST",
+			expectedResBody: `Method Not Allowed`,
+		}, {
+			name:            "POST /users/1",
+			method:          "POST",
+			path:            "/users/1",
+			expectedCode:    405,
+			expectedAllow:   "DELETE, GET, HEAD, OPTIONS, PATCH, PUT",
+			expectedResBody: `Method Not Allowed`,
+		}, {
+			name:             "POST /items/",
+			method:           "POST",
+			path:             "/items/",
+			reqBody:          `{"name":"Pen"}`,
+			expectedCode:     201,
+			expectedLocation: "/items/1",
+			expectedResBody:  `{"id":"1","name":"Pen"}`,
+		}, {
+			name:            "GET /items/1",
+			method:          "GET",
+			path:            "/items/1",
+			expectedCode:    200,
+			expectedResBody: `{"id":"1","name":"Pen"}`,
+		}, {
+			name:            "GET /health",
+			method:          "GET",
+			path:            "/health",
+			expectedCode:    200,
+			expectedResBody: `ok`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.reqBody))
+			mux.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := test.expectedAllow, w.Header().Get("Allow"); e != g {
+				t.Errorf("expected Allow %s, got %s", e, g)
+			}
+			if e, g := test.expectedLocation, w.Header().Get("Location"); e != g {
+				t.Errorf("expected Location %s, got %s", e, g)
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
+
+func TestHandleMuxReadOnly(t *testing.T) {
+	mux := http.NewServeMux()
+	ghost.HandleMux[User, SearchQuery, uint64](mux, "/users", readOnlyStore{ghost.NewMapStore(User{}, SearchQuery{}, uint64(0))})
+
+	tests := []struct {
+		name, method, path, reqBody string
+		expectedCode                int
+		expectedHeader              map[string]string
+		expectedResBody             string
+	}{
+		{
+			name:         "HEAD /users/ read only",
+			method:       "HEAD",
+			path:         "/users/",
+			expectedCode: 200,
+			// listed, as readOnlyStore isn't a Counter
+			expectedHeader: map[string]string{
+				"Content-Type":  "application/json",
+				"X-Total-Count": "",
+			},
+		}, {
+			name:         "OPTIONS /users/1 read only",
+			method:       "OPTIONS",
+			path:         "/users/1",
+			expectedCode: 204,
+			expectedHeader: map[string]string{
+				"Allow": "GET, HEAD, OPTIONS",
+			},
+		}, {
+			name:            "PUT /users/1 read only",
+			method:          "PUT",
+			path:            "/users/1",
+			reqBody:         `{"Name":"Alice"}`,
+			expectedCode:    405,
+			expectedResBody: `Method Not Allowed`,
+			expectedHeader: map[string]string{
+				"Allow": "GET, HEAD, OPTIONS",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.reqBody))
+			mux.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			for k, e := range test.expectedHeader {
+				if g := w.Header().Get(k); e != g {
+					t.Errorf("expected %s %s, got %s", k, e, g)
+				}
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package ghost
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ProblemType is the media type of problem details in JSON.
+const ProblemType = "application/problem+json"
+
+// Problem is a problem details object, see https://www.rfc-editor.org/rfc/rfc7807.
+type Problem struct {
+	Type     string
+	Title    string
+	Status   int
+	Detail   string
+	Instance string
+	// Extensions are encoded as members alongside the others, which they can't override.
+	Extensions map[string]any
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	m["type"] = p.Type
+	m["title"] = p.Title
+	m["status"] = p.Status
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return json.Marshal(m)
+}
+
+func (p *Problem) UnmarshalJSON(b []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	*p = Problem{}
+	for k, v := range m {
+		var err error
+		switch k {
+		case "type":
+			err = json.Unmarshal(v, &p.Type)
+		case "title":
+			err = json.Unmarshal(v, &p.Title)
+		case "status":
+			err = json.Unmarshal(v, &p.Status)
+		case "detail":
+			err = json.Unmarshal(v, &p.Detail)
+		case "instance":
+			err = json.Unmarshal(v, &p.Instance)
+		default:
+			var ext any
+			err = json.Unmarshal(v, &ext)
+			if p.Extensions == nil {
+				p.Extensions = map[string]any{}
+			}
+			p.Extensions[k] = ext
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// ProblemOf returns the problem details of err, which occurred while handling r.
+// The Type of an Error defaults to about:blank, and the Title is the status text.
+// Errors which aren't Errors are internal server errors, see ProductionErrorHandler to hide their details.
+func ProblemOf(err error, r *http.Request) Problem {
+	e := Error{Code: http.StatusInternalServerError, Err: err}
+	errors.As(err, &e)
+	p := Problem{
+		Type:       e.Type,
+		Title:      http.StatusText(e.Code),
+		Status:     e.Code,
+		Detail:     e.Err.Error(),
+		Instance:   requestPath(r),
+		Extensions: e.extensions(),
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	return p
+}
+
+// ProblemErrorHandler encodes errors as problem details with the Encoding negotiated among encodings,
+// or the default Encoding if none is acceptable.
+// Use Encodings[Problem]{ProblemJSON{}} for application/problem+json.
+// If encodings is empty, it responds with a plain text 500 Internal Server Error.
+func ProblemErrorHandler(encodings Encodings[Problem]) func(err error) http.Handler {
+	return func(err error) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// on failure, encoding is the default
+			encoding, _ := encodings.Response(r)
+			if encoding == nil {
+				noEncodings(w)
+				return
+			}
+			p := ProblemOf(err, r)
+			_ = encoding.Encode(w, p, p.Status)
+		})
+	}
+}
+
+// ProblemJSON is an Encoding of Problem with the application/problem+json media type.
+type ProblemJSON struct{}
+
+func (j ProblemJSON) MediaType() string {
+	return ProblemType
+}
+
+func (j ProblemJSON) Encode(w http.ResponseWriter, p Problem, code int) error {
+	w.Header().Set("Content-Type", ProblemType)
+	w.WriteHeader(code)
+	return json.NewEncoder(w).Encode(p)
+}
+
+func (j ProblemJSON) EncodeList(w http.ResponseWriter, ps []Problem, code int) error {
+	w.Header().Set("Content-Type", ProblemType)
+	w.WriteHeader(code)
+	return json.NewEncoder(w).Encode(ps)
+}
+
+func (j ProblemJSON) EncodeEmpty(w http.ResponseWriter, code int) error {
+	w.Header().Set("Content-Type", ProblemType)
+	w.WriteHeader(code)
+	return nil
+}
+
+func (j ProblemJSON) Decode(r *http.Request) (Problem, error) {
+	var p Problem
+	err := json.NewDecoder(r.Body).Decode(&p)
+	return p, err
+}
@@ -0,0 +1,180 @@
+package ghost_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mash/ghost"
+)
+
+// outOfCredit is an error with the balance extension.
+type outOfCredit struct {
+	balance int
+}
+
+func (e outOfCredit) Error() string {
+	return fmt.Sprintf("Your current balance is %d, but that costs 50.", e.balance)
+}
+
+func (e outOfCredit) Extensions() map[string]any {
+	return map[string]any{"balance": e.balance}
+}
+
+type Account struct {
+	Name string
+}
+
+func (a *Account) BeforeCreate(ctx context.Context) error {
+	switch a.Name {
+	case "poor":
+		return ghost.Error{
+			Code: http.StatusForbidden,
+			Err:  outOfCredit{balance: 30},
+			Type: "https://example.com/probs/out-of-credit",
+		}
+	case "broken":
+		return fmt.Errorf("INSERT INTO accounts: connection refused")
+	}
+	return nil
+}
+
+func TestProblem(t *testing.T) {
+	var logs bytes.Buffer
+	newGhost := func(production bool) http.Handler {
+		store := ghost.NewHookStore(ghost.NewMapStore(Account{}, SearchQuery{}, uint64(0)))
+		errorHandler := ghost.ProblemErrorHandler(ghost.Encodings[ghost.Problem]{ghost.ProblemJSON{}})
+		if production {
+			errorHandler = ghost.ProductionErrorHandler(errorHandler, log.New(&logs, "", 0))
+		}
+		return ghost.Ghost[Account, SearchQuery, uint64]{
+			Server: ghost.NewServer[Account, SearchQuery, uint64](
+				store,
+				ghost.JSON[Account]{},
+				ghost.PathIdentifier[uint64](ghost.UintPath[uint64]),
+				ghost.NewQueryParser[SearchQuery](),
+			),
+			Mux:          ghost.DefaultMux[Account, SearchQuery],
+			ErrorHandler: errorHandler,
+		}
+	}
+
+	tests := []struct {
+		name, method, path, reqBody string
+		production                  bool
+		expectedCode                int
+		expectedResBody             map[string]any
+	}{
+		{
+			name:         "not found",
+			method:       "GET",
+			path:         "/1",
+			expectedCode: 404,
+			expectedResBody: map[string]any{
+				"type":     "about:blank",
+				"title":    "Not Found",
+				"status":   404.,
+				"detail":   "Not Found",
+				"instance": "/1",
+			},
+		}, {
+			name:         "type and extensions",
+			method:       "POST",
+			path:         "/",
+			reqBody:      `{"Name":"poor"}`,
+			expectedCode: 403,
+			expectedResBody: map[string]any{
+				"type":     "https://example.com/probs/out-of-credit",
+				"title":    "Forbidden",
+				"status":   403.,
+				"detail":   "Your current balance is 30, but that costs 50.",
+				"instance": "/",
+				"balance":  30.,
+			},
+		}, {
+			name:         "internal error",
+			method:       "POST",
+			path:         "/",
+			reqBody:      `{"Name":"broken"}`,
+			expectedCode: 500,
+			expectedResBody: map[string]any{
+				"type":     "about:blank",
+				"title":    "Internal Server Error",
+				"status":   500.,
+				"detail":   "INSERT INTO accounts: connection refused",
+				"instance": "/",
+			},
+		}, {
+			name:         "internal error in production",
+			method:       "POST",
+			path:         "/",
+			reqBody:      `{"Name":"broken"}`,
+			production:   true,
+			expectedCode: 500,
+			expectedResBody: map[string]any{
+				"type":     "about:blank",
+				"title":    "Internal Server Error",
+				"status":   500.,
+				"detail":   "Internal Server Error",
+				"instance": "/",
+			},
+		}, {
+			name:         "error in production",
+			method:       "GET",
+			path:         "/1",
+			production:   true,
+			expectedCode: 404,
+			expectedResBody: map[string]any{
+				"type":     "about:blank",
+				"title":    "Not Found",
+				"status":   404.,
+				"detail":   "Not Found",
+				"instance": "/1",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.reqBody))
+			newGhost(test.production).ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := "application/problem+json", w.Header().Get("Content-Type"); e != g {
+				t.Errorf("expected Content-Type %s, got %s", e, g)
+			}
+			var body map[string]any
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode json body: %v", err)
+			}
+			if diff := cmp.Diff(test.expectedResBody, body); diff != "" {
+				t.Errorf("unexpected body (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if e, g := "POST /: INSERT INTO accounts: connection refused\n", logs.String(); e != g {
+		t.Errorf("expected log %q, got %q", e, g)
+	}
+	if !errors.Is(fmt.Errorf("wrapped: %w", ghost.ErrNotFound), ghost.ErrNotFound) {
+		t.Errorf("expected a wrapped ErrNotFound to be ErrNotFound")
+	}
+	// Errors are comparable, even with extensions
+	var err error = ghost.Error{Code: http.StatusForbidden, Err: outOfCredit{balance: 30}}
+	if err == ghost.ErrNotFound {
+		t.Errorf("expected %v not to be ErrNotFound", err)
+	}
+	if err = ghost.ErrNotFound; err != ghost.ErrNotFound {
+		t.Errorf("expected %v to be ErrNotFound", err)
+	}
+}
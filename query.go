@@ -1,7 +1,11 @@
 package ghost
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
 
 	"github.com/gorilla/schema"
 )
@@ -23,8 +27,60 @@ func NewQueryParser[Q Query]() QueryParser[Q] {
 	}
 }
 
+// Query decodes the URL query parameters except the PageParams into a Query.
+// Invalid, unknown and missing required parameters are 400 Bad Request Errors with QueryParamErrors.
 func (qp QueryParser[Q]) Query(r *http.Request) (Q, error) {
 	var q Q
-	err := qp.decoder.Decode(&q, r.URL.Query())
-	return q, err
+	values := r.URL.Query()
+	for _, p := range PageParams {
+		values.Del(p)
+	}
+	if err := qp.decoder.Decode(&q, values); err != nil {
+		return q, queryError(err)
+	}
+	return q, nil
+}
+
+// queryError converts an error of schema.Decoder into a 400 Bad Request Error with QueryParamErrors.
+func queryError(err error) error {
+	var me schema.MultiError
+	if !errors.As(err, &me) {
+		return Error{Code: http.StatusBadRequest, Err: err}
+	}
+	qes := make(QueryParamErrors, 0, len(me))
+	for param, err := range me {
+		qes = append(qes, QueryParamError{Param: param, Err: queryParamCause(err)})
+	}
+	sort.Slice(qes, func(i, j int) bool {
+		return qes[i].Param < qes[j].Param
+	})
+	return Error{Code: http.StatusBadRequest, Err: qes}
+}
+
+// queryParamCause rephrases the errors of schema.Decoder, which already name the parameter.
+func queryParamCause(err error) error {
+	var ce schema.ConversionError
+	var ue schema.UnknownKeyError
+	var ee schema.EmptyFieldError
+	switch {
+	case errors.As(err, &ce):
+		cause := ce.Err
+		var ne *strconv.NumError
+		if errors.As(cause, &ne) {
+			cause = ne.Err
+		}
+		msg := "invalid value"
+		if ce.Index >= 0 {
+			msg = fmt.Sprintf("invalid value at index %d", ce.Index)
+		}
+		if cause != nil {
+			return fmt.Errorf("%s: %w", msg, cause)
+		}
+		return errors.New(msg)
+	case errors.As(err, &ue):
+		return errors.New("unknown parameter")
+	case errors.As(err, &ee):
+		return errors.New("missing required parameter")
+	}
+	return err
 }
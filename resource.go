@@ -1,3 +1,34 @@
 package ghost
 
 type Resource any
+
+// Identifiable is implemented by resources which carry their own PKey.
+// Stores which assign PKeys on Create set them with SetPKey,
+// and the server uses PKey to respond with the Location of created resources.
+type Identifiable[P PKey] interface {
+	PKey() P
+	SetPKey(P)
+}
+
+// Child is implemented by resources which are nested under a parent whose PKey is P, see Nested.
+// The server sets the ParentKey of created and updated resources to the PKey of the parent of the request,
+// and stores list, read, update and delete only the resources of the parent of the request, see InParent.
+type Child[P PKey] interface {
+	ParentKey() P
+	SetParentKey(P)
+}
+
+// Versioned is implemented by resources which carry a version, which changes whenever the resource does.
+// The server derives the ETag of the resource from the version instead of hashing the encoded resource,
+// along with the media type if there are several Encodings.
+type Versioned interface {
+	Version() string
+}
+
+// VersionField is implemented by Versioned resources whose version is a counter in a field.
+// Stores set it to 1 on Create and increment it on every Update,
+// and ConditionalStores check it to update and delete resources only if they haven't changed.
+type VersionField interface {
+	Versioned
+	SetVersion(uint64)
+}
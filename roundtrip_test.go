@@ -0,0 +1,210 @@
+package ghost_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/mash/ghost"
+	ghostcbor "github.com/mash/ghost/encoding/cbor"
+	ghostmsgpack "github.com/mash/ghost/encoding/msgpack"
+	ghostxml "github.com/mash/ghost/encoding/xml"
+	ghostyaml "github.com/mash/ghost/encoding/yaml"
+	v "github.com/mash/ghost/store/validator"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// Member is validated, so that its errors have extensions.
+type Member struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name" validate:"required"`
+}
+
+func (m *Member) PKey() uint64 {
+	return m.ID
+}
+
+func (m *Member) SetPKey(id uint64) {
+	m.ID = id
+}
+
+// errorBody decodes ghost.Errors in all the encodings.
+type errorBody struct {
+	Error  string         `json:"error" yaml:"error" xml:",chardata"`
+	Errors []v.FieldError `json:"errors" yaml:"errors" xml:"errors"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		encoding  ghost.Encoding[Member]
+		errors    ghost.Encoding[ghost.Error]
+		marshal   func(any) ([]byte, error)
+		unmarshal func([]byte, any) error
+	}{
+		{
+			name:     "xml",
+			encoding: ghostxml.Encoding[Member]{},
+			errors:   ghostxml.Encoding[ghost.Error]{},
+			marshal:  xml.Marshal,
+			unmarshal: func(b []byte, v any) error {
+				// lists are wrapped in a root element
+				if ms, ok := v.(*[]Member); ok {
+					var list struct {
+						Members []Member `xml:"Member"`
+					}
+					err := xml.Unmarshal(b, &list)
+					*ms = list.Members
+					return err
+				}
+				return xml.Unmarshal(b, v)
+			},
+		}, {
+			name:      "yaml",
+			encoding:  ghostyaml.Encoding[Member]{},
+			errors:    ghostyaml.Encoding[ghost.Error]{},
+			marshal:   yaml.Marshal,
+			unmarshal: yaml.Unmarshal,
+		}, {
+			name:     "msgpack",
+			encoding: ghostmsgpack.Encoding[Member]{},
+			errors:   ghostmsgpack.Encoding[ghost.Error]{},
+			marshal: func(v any) ([]byte, error) {
+				var b bytes.Buffer
+				enc := msgpack.NewEncoder(&b)
+				enc.SetCustomStructTag("json")
+				err := enc.Encode(v)
+				return b.Bytes(), err
+			},
+			unmarshal: func(b []byte, v any) error {
+				dec := msgpack.NewDecoder(bytes.NewReader(b))
+				dec.SetCustomStructTag("json")
+				return dec.Decode(v)
+			},
+		}, {
+			name:      "cbor",
+			encoding:  ghostcbor.Encoding[Member]{},
+			errors:    ghostcbor.Encoding[ghost.Error]{},
+			marshal:   cbor.Marshal,
+			unmarshal: cbor.Unmarshal,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testRoundTrip(t, test.encoding, test.errors, test.marshal, test.unmarshal)
+		})
+	}
+}
+
+// testRoundTrip creates, lists and reads Members, and fails to, in the media type of encoding,
+// marshaling the requests with marshal and unmarshaling the responses with unmarshal.
+func testRoundTrip(t *testing.T, encoding ghost.Encoding[Member], errors ghost.Encoding[ghost.Error], marshal func(any) ([]byte, error), unmarshal func([]byte, any) error) {
+	t.Helper()
+	g := ghost.Ghost[Member, SearchQuery, uint64]{
+		Server: ghost.NewNegotiatingServer[Member, SearchQuery, uint64](
+			v.NewStore(ghost.NewMapStore(Member{}, SearchQuery{}, uint64(0)), validator.New()),
+			ghost.Encodings[Member]{ghost.JSON[Member]{}, encoding},
+			ghost.PathIdentifier[uint64](ghost.UintPath[uint64]),
+			ghost.NewQueryParser[SearchQuery](),
+		),
+		Mux:          ghost.DefaultMux[Member, SearchQuery],
+		ErrorHandler: ghost.NegotiatingErrorHandler(ghost.Encodings[ghost.Error]{ghost.JSON[ghost.Error]{}, errors}),
+	}
+	mediaType := encoding.(ghost.MediaTyper).MediaType()
+
+	tests := []struct {
+		name, method, path string
+		reqBody            any
+		expectedCode       int
+		resBody            any
+		expectedResBody    any
+	}{
+		{
+			name:            "GET / empty",
+			method:          "GET",
+			path:            "/",
+			expectedCode:    200,
+			resBody:         &[]Member{},
+			expectedResBody: &[]Member{},
+		}, {
+			name:            "POST /",
+			method:          "POST",
+			path:            "/",
+			reqBody:         Member{Name: "John"},
+			expectedCode:    201,
+			resBody:         &Member{},
+			expectedResBody: &Member{ID: 1, Name: "John"},
+		}, {
+			name:            "GET /",
+			method:          "GET",
+			path:            "/",
+			expectedCode:    200,
+			resBody:         &[]Member{},
+			expectedResBody: &[]Member{{ID: 1, Name: "John"}},
+		}, {
+			name:            "GET /1",
+			method:          "GET",
+			path:            "/1",
+			expectedCode:    200,
+			resBody:         &Member{},
+			expectedResBody: &Member{ID: 1, Name: "John"},
+		}, {
+			name:            "GET /2",
+			method:          "GET",
+			path:            "/2",
+			expectedCode:    404,
+			resBody:         &errorBody{},
+			expectedResBody: &errorBody{Error: "Not Found"},
+		}, {
+			name:         "POST / invalid",
+			method:       "POST",
+			path:         "/",
+			reqBody:      Member{},
+			expectedCode: 400,
+			resBody:      &errorBody{},
+			expectedResBody: &errorBody{
+				Error: "Field validation for 'name' failed on the 'required' tag",
+				Errors: []v.FieldError{
+					{Field: "name", JSONPath: "name", Tag: "required", Message: "Field validation for 'name' failed on the 'required' tag"},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var body io.Reader
+			if test.reqBody != nil {
+				b, err := marshal(test.reqBody)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = bytes.NewReader(b)
+			}
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, body)
+			r.Header.Set("Content-Type", mediaType)
+			r.Header.Set("Accept", mediaType)
+			g.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := mediaType, w.Header().Get("Content-Type"); e != g {
+				t.Errorf("expected Content-Type %s, got %s", e, g)
+			}
+			if err := unmarshal(w.Body.Bytes(), test.resBody); err != nil {
+				t.Fatalf("%s: %v", w.Body.String(), err)
+			}
+			if diff := cmp.Diff(test.expectedResBody, test.resBody, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("(-expected +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,45 +1,130 @@
 package ghost
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
 )
 
 type Server interface {
 	Create(http.ResponseWriter, *http.Request) error
 	Read(http.ResponseWriter, *http.Request) error
 	Update(http.ResponseWriter, *http.Request) error
+	Patch(http.ResponseWriter, *http.Request) error
 	Delete(http.ResponseWriter, *http.Request) error
 	List(http.ResponseWriter, *http.Request) error
 }
 
 type server[R Resource, Q Query, P PKey] struct {
 	store      Store[R, Q, P]
-	encoding   Encoding[R]
+	encodings  Encodings[R]
 	identifier Identifier[P]
 	querier    Querier[Q]
+	paging     Paging
 }
 
+// NewServer returns a Server.
 func NewServer[R Resource, Q Query, P PKey](store Store[R, Q, P], encoding Encoding[R], identifier Identifier[P], querier Querier[Q]) Server {
+	return NewNegotiatingServer(store, Encodings[R]{encoding}, identifier, querier)
+}
+
+// NewNegotiatingServer returns a Server.
+// The Encoding of each request and response is negotiated among encodings, the first of which is the default.
+// If encodings is empty, the Server responds to every request with an error, which is a 500 Internal Server Error.
+func NewNegotiatingServer[R Resource, Q Query, P PKey](store Store[R, Q, P], encodings Encodings[R], identifier Identifier[P], querier Querier[Q]) Server {
 	return server[R, Q, P]{
 		store:      store,
-		encoding:   encoding,
+		encodings:  encodings,
 		identifier: identifier,
 		querier:    querier,
 	}
 }
 
 func (g server[R, Q, P]) Create(w http.ResponseWriter, r *http.Request) error {
-	res, err := g.encoding.Decode(r)
+	enc, err := g.response(w, r)
+	if err != nil {
+		return err
+	}
+	dec, err := g.encodings.Request(r)
 	if err != nil {
 		return err
 	}
+	res, err := dec.Decode(r)
+	if err != nil {
+		return bodyError(err)
+	}
+	SetParent(r.Context(), &res)
 	if err := g.store.Create(r.Context(), &res); err != nil {
 		return err
 	}
-	return g.encoding.Encode(w, res, http.StatusCreated)
+	if i, ok := any(&res).(Identifiable[P]); ok {
+		w.Header().Set("Location", location(r, i.PKey()))
+	}
+	return enc.Encode(w, res, http.StatusCreated)
+}
+
+// response negotiates the Encoding of the response.
+func (g server[R, Q, P]) response(w http.ResponseWriter, r *http.Request) (Encoding[R], error) {
+	if len(g.encodings) > 1 {
+		w.Header().Add("Vary", "Accept")
+	}
+	return g.encodings.Response(r)
+}
+
+// json returns the JSON Encoding among the encodings, whose fields apply to patches too.
+func (g server[R, Q, P]) json() JSON[R] {
+	for _, enc := range g.encodings {
+		switch j := enc.(type) {
+		case JSON[R]:
+			return j
+		case *JSON[R]:
+			return *j
+		}
+	}
+	return JSON[R]{}
+}
+
+// location returns the path of the resource identified by pkey in the collection which r was sent to.
+func location[P PKey](r *http.Request, pkey P) string {
+	return strings.TrimSuffix(requestPath(r), "/") + "/" + url.PathEscape(fmt.Sprint(pkey))
+}
+
+// requestPath returns the path of the RequestURI, which unlike URL.Path is not rewritten by http.StripPrefix.
+func requestPath(r *http.Request) string {
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		return u.Path
+	}
+	return r.URL.Path
+}
+
+// WithPaging returns s, which NewServer or NewNegotiatingServer returned, limiting the pages which it lists by paging.
+// Other Servers are returned as they are.
+func WithPaging(s Server, paging Paging) Server {
+	if p, ok := s.(interface{ withPaging(Paging) Server }); ok {
+		return p.withPaging(paging)
+	}
+	return s
+}
+
+func (g server[R, Q, P]) withPaging(paging Paging) Server {
+	g.paging = paging
+	return g
 }
 
+// Read responds with the ETag of the resource, and 304 Not Modified if it matches the If-None-Match header.
 func (g server[R, Q, P]) Read(w http.ResponseWriter, r *http.Request) error {
+	enc, err := g.response(w, r)
+	if err != nil {
+		return err
+	}
 	pkey, err := g.identifier.PKey(r)
 	if err != nil {
 		return err
@@ -52,43 +137,356 @@ func (g server[R, Q, P]) Read(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
-	return g.encoding.Encode(w, *res, http.StatusOK)
+	var etag string
+	if v, ok := any(res).(Versioned); ok {
+		etag = g.versionETag(v, enc)
+		if !noneMatch(r, etag) {
+			return notModified(w, etag)
+		}
+	}
+	b := &bufferedResponse{w: w}
+	if err := enc.Encode(b, *res, http.StatusOK); err != nil {
+		return err
+	}
+	return b.respond(r, etag)
 }
 
+// Update replaces the resource.
+// If the request has an If-Match header, the resource is replaced only if its ETag matches,
+// otherwise the response is 412 Precondition Failed.
+// The check is atomic with the write if R is Versioned and the store is a ConditionalStore.
 func (g server[R, Q, P]) Update(w http.ResponseWriter, r *http.Request) error {
+	enc, err := g.response(w, r)
+	if err != nil {
+		return err
+	}
+	pkey, err := g.identifier.PKey(r)
+	if err != nil {
+		return err
+	}
+	dec, err := g.encodings.Request(r)
+	if err != nil {
+		return err
+	}
+	res, err := dec.Decode(r)
+	if err != nil {
+		return bodyError(err)
+	}
+	SetParent(r.Context(), &res)
+	if tags, ok := ifMatch(r); ok {
+		if version, ok := g.conditionalVersion(tags); ok {
+			err = g.store.(ConditionalStore[R, P]).UpdateIf(r.Context(), pkey, &res, version)
+		} else if err = g.precondition(r, enc, pkey, tags); err == nil {
+			err = g.store.Update(r.Context(), pkey, &res)
+		}
+	} else {
+		err = g.store.Update(r.Context(), pkey, &res)
+	}
+	if err != nil {
+		return err
+	}
+	if v, ok := any(&res).(Versioned); ok {
+		w.Header().Set("ETag", g.versionETag(v, enc))
+	}
+	return enc.Encode(w, res, http.StatusOK)
+}
+
+// versionETag returns the ETag of v in enc.
+// If there are several Encodings, the media type is a part of it, so that every representation has its own strong ETag.
+func (g server[R, Q, P]) versionETag(v Versioned, enc Encoding[R]) string {
+	if len(g.encodings) > 1 {
+		return versionETag(v.Version(), mediaType(enc))
+	}
+	return versionETag(v.Version(), "")
+}
+
+// version returns the version in the ETag of any representation, see versionETag.
+func (g server[R, Q, P]) version(tag string) (string, bool) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return "", false
+	}
+	tag = tag[1 : len(tag)-1]
+	if len(g.encodings) == 1 {
+		return tag, true
+	}
+	for _, enc := range g.encodings {
+		if mt := mediaType(enc); mt != "" {
+			if strings.HasSuffix(tag, ";"+mt) {
+				return strings.TrimSuffix(tag, ";"+mt), true
+			}
+		}
+	}
+	if _, ok := g.encodings.any(); ok {
+		return tag, true
+	}
+	return "", false
+}
+
+// conditionalVersion returns the version in the If-Match entity tags, if the store can check it atomically:
+// R is Versioned, the store is a ConditionalStore, and tags is a single strong entity tag.
+func (g server[R, Q, P]) conditionalVersion(tags []string) (string, bool) {
+	var r R
+	if _, ok := any(&r).(Versioned); !ok || !Supports[ConditionalStore[R, P]](g.store) || len(tags) != 1 {
+		return "", false
+	}
+	return g.version(tags[0])
+}
+
+// precondition returns ErrPreconditionFailed if none of the If-Match entity tags match the ETag of the stored resource.
+// It isn't atomic with the following write.
+func (g server[R, Q, P]) precondition(r *http.Request, enc Encoding[R], pkey P, tags []string) error {
+	var q Q
+	cur, err := g.store.Read(r.Context(), pkey, &q)
+	if err != nil {
+		return err
+	}
+	return g.matches(enc, cur, tags)
+}
+
+// matches returns ErrPreconditionFailed if none of the If-Match entity tags match the ETag of cur.
+// The version of a Versioned resource matches in any representation.
+func (g server[R, Q, P]) matches(enc Encoding[R], cur *R, tags []string) error {
+	if v, ok := any(cur).(Versioned); ok {
+		for _, tag := range tags {
+			if version, ok := g.version(tag); tag == "*" || ok && version == v.Version() {
+				return nil
+			}
+		}
+		return ErrPreconditionFailed
+	}
+	b := &bufferedResponse{}
+	if err := enc.Encode(b, *cur, http.StatusOK); err != nil {
+		return err
+	}
+	if !match(tags, bodyETag(b.body.Bytes())) {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// Patch applies a JSON Merge Patch (RFC 7396) or, when the Content-Type is application/json-patch+json,
+// a JSON Patch (RFC 6902) to the resource.
+// The patch is applied to the stored resource, which is written back with Update, or with UpdateIf against the version
+// which was read if R is Versioned and the store is a ConditionalStore, so that concurrent writes are 412 Precondition Failed.
+// If the request has an If-Match header, the stored resource is patched only if its ETag matches, like Update.
+// Otherwise merge patches are written with Patch if the store is a Patcher, so that only the fields in the patch are written.
+func (g server[R, Q, P]) Patch(w http.ResponseWriter, r *http.Request) error {
+	enc, err := g.response(w, r)
+	if err != nil {
+		return err
+	}
 	pkey, err := g.identifier.PKey(r)
 	if err != nil {
 		return err
 	}
-	res, err := g.encoding.Decode(r)
+	q, err := g.querier.Query(r)
+	if err != nil {
+		return err
+	}
+	j := g.json()
+	mt := MergePatchType
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, err = mime.ParseMediaType(ct); err != nil {
+			return ErrUnsupportedMediaType
+		}
+	} else if j.RequireContentType {
+		return ErrUnsupportedMediaType
+	}
+	patch, err := io.ReadAll(j.limit(r.Body))
+	if err != nil {
+		return bodyError(err)
+	}
+	if mt != MergePatchType && mt != "application/json" && mt != JSONPatchType {
+		return ErrUnsupportedMediaType
+	}
+	// the limits of the JSON Encoding apply to patches too, so data after the patch is ignored unless DisallowTrailingData
+	var raw json.RawMessage
+	if err := j.decodeValue(bytes.NewReader(patch), &raw); err != nil {
+		return bodyError(err)
+	}
+	patch = raw
+	var keys map[string]json.RawMessage
+	if mt != JSONPatchType {
+		if j.DisallowUnknownFields {
+			if _, err := j.decode(bytes.NewReader(patch)); err != nil {
+				return bodyError(err)
+			}
+		}
+		if err := json.Unmarshal(patch, &keys); err != nil {
+			return bodyError(err)
+		}
+	}
+	cur, err := g.store.Read(r.Context(), pkey, &q)
 	if err != nil {
 		return err
 	}
-	if err := g.store.Update(r.Context(), pkey, &res); err != nil {
+	tags, conditional := ifMatch(r)
+	if conditional {
+		if err := g.matches(enc, cur, tags); err != nil {
+			return err
+		}
+	}
+	var res *R
+	if mt == JSONPatchType {
+		res, err = g.jsonPatch(r, pkey, cur, patch, j.DisallowUnknownFields)
+	} else {
+		res, err = g.mergePatch(r, pkey, &q, cur, patch, keys, conditional)
+	}
+	if err != nil {
 		return err
 	}
-	return g.encoding.Encode(w, res, http.StatusOK)
+	if v, ok := any(res).(Versioned); ok {
+		w.Header().Set("ETag", g.versionETag(v, enc))
+	}
+	return enc.Encode(w, *res, http.StatusOK)
+}
+
+func (g server[R, Q, P]) jsonPatch(r *http.Request, pkey P, cur *R, patch []byte, disallowUnknownFields bool) (*R, error) {
+	res, err := jsonPatch(*cur, patch, disallowUnknownFields)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.updateFrom(r, pkey, cur, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// updateFrom writes res, which was derived from cur, back to the store.
+// If R is Versioned and the store is a ConditionalStore, it is written with UpdateIf against the version of cur,
+// so that a concurrent write in between is ErrPreconditionFailed instead of being overwritten.
+func (g server[R, Q, P]) updateFrom(r *http.Request, pkey P, cur *R, res *R) error {
+	SetParent(r.Context(), res)
+	if v, ok := any(cur).(Versioned); ok && Supports[ConditionalStore[R, P]](g.store) {
+		return g.store.(ConditionalStore[R, P]).UpdateIf(r.Context(), pkey, res, v.Version())
+	}
+	return g.store.Update(r.Context(), pkey, res)
+}
+
+// mergePatch merges patch, whose members are keys, onto cur.
+// Unless conditional, it is written with Patch if the store is a Patcher.
+func (g server[R, Q, P]) mergePatch(r *http.Request, pkey P, q *Q, cur *R, patch []byte, keys map[string]json.RawMessage, conditional bool) (*R, error) {
+	res, err := mergePatch(*cur, patch)
+	if err != nil {
+		return nil, bodyError(err)
+	}
+	if !conditional && Supports[Patcher[R, P]](g.store) {
+		// nested objects are merged with the stored ones, but only the fields in the patch are written
+		SetParent(r.Context(), &res)
+		if err := g.store.(Patcher[R, P]).Patch(r.Context(), pkey, &res, patchFields[R](keys)); err != nil {
+			return nil, err
+		}
+		return g.store.Read(r.Context(), pkey, q)
+	}
+	if err := g.updateFrom(r, pkey, cur, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
 }
 
+// Delete deletes the resource, and honors the If-Match header like Update.
 func (g server[R, Q, P]) Delete(w http.ResponseWriter, r *http.Request) error {
+	enc, err := g.response(w, r)
+	if err != nil {
+		return err
+	}
 	pkey, err := g.identifier.PKey(r)
 	if err != nil {
 		return err
 	}
-	if err := g.store.Delete(r.Context(), pkey); err != nil {
+	if tags, ok := ifMatch(r); ok {
+		if version, ok := g.conditionalVersion(tags); ok {
+			err = g.store.(ConditionalStore[R, P]).DeleteIf(r.Context(), pkey, version)
+		} else if err = g.precondition(r, enc, pkey, tags); err == nil {
+			err = g.store.Delete(r.Context(), pkey)
+		}
+	} else {
+		err = g.store.Delete(r.Context(), pkey)
+	}
+	if err != nil {
 		return err
 	}
-	return g.encoding.EncodeEmpty(w, http.StatusNoContent)
+	return enc.EncodeEmpty(w, http.StatusNoContent)
+}
+
+// Operations returns the operations of the store.
+func (g server[R, Q, P]) Operations() []Operation {
+	return Operations(g.store)
+}
+
+// Head responds like Read or List without the body, so that the headers are those of GET.
+func (g server[R, Q, P]) Head(w http.ResponseWriter, r *http.Request) error {
+	w = headResponse{w}
+	if _, f := path.Split(r.URL.Path); f != "" {
+		return g.Read(w, r)
+	}
+	return g.List(w, r)
 }
 
+// List responds with the ETag of the encoded list, and 304 Not Modified if it matches the If-None-Match header.
+// If the store is a Counter, the count of the resources which match the query, regardless of pagination,
+// is in the X-Total-Count header, except for streamed lists.
+// Streamed lists don't have ETags, as they aren't buffered, nor Link headers, as they aren't paginated.
+// Lists are streamed only if they aren't paginated, which they always are with a DefaultLimit or a MaxLimit, see WithPaging.
 func (g server[R, Q, P]) List(w http.ResponseWriter, r *http.Request) error {
+	enc, err := g.response(w, r)
+	if err != nil {
+		return err
+	}
 	q, err := g.querier.Query(r)
 	if err != nil {
 		return err
 	}
-	res, err := g.store.List(r.Context(), &q)
+	page, err := parsePage(r)
+	if err != nil {
+		return err
+	}
+	page = g.paging.limit(page)
+	if se, ok := enc.(StreamEncoding[R]); ok && page == (Page{}) && Supports[Streamer[R, Q]](g.store) {
+		return g.stream(w, r, se, &q)
+	}
+	var res []R
+	var next string
+	if Supports[PagedStore[R, Q]](g.store) {
+		res, next, err = g.store.(PagedStore[R, Q]).ListPage(r.Context(), &q, page)
+	} else if res, err = g.store.List(r.Context(), &q); err == nil {
+		res, next, err = PageOf(res, page)
+	}
 	if err != nil {
 		return err
 	}
-	return g.encoding.EncodeList(w, res, http.StatusOK)
+	if Supports[Counter[Q]](g.store) {
+		// q already went through the BeforeList hook
+		n, err := g.store.(Counter[Q]).Count(withListed(r.Context()), &q)
+		if err == nil {
+			w.Header().Set("X-Total-Count", strconv.Itoa(n))
+		} else if !errors.Is(err, ErrNotImplemented) {
+			return err
+		}
+	}
+	if next != "" {
+		w.Header().Set("Link", nextLink(r, page, next))
+	}
+	b := &bufferedResponse{w: w}
+	if err := enc.EncodeList(b, res, http.StatusOK); err != nil {
+		return err
+	}
+	return b.respond(r, "")
+}
+
+// stream encodes the resources as the store yields them.
+// Once the first resource is written the response can't be replaced by an error response,
+// so errors after that are StreamErrors, see Ghost.
+func (g server[R, Q, P]) stream(w http.ResponseWriter, r *http.Request, enc StreamEncoding[R], q *Q) error {
+	written := false
+	err := enc.EncodeStream(w, http.StatusOK, func(yield func(R) error) error {
+		return g.store.(Streamer[R, Q]).Stream(r.Context(), q, func(res R) error {
+			written = true
+			return yield(res)
+		})
+	})
+	if err != nil && written {
+		return StreamError{Err: err}
+	}
+	return err
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"sync"
 )
 
 type Store[R Resource, Q Query, P PKey] interface {
@@ -14,6 +15,89 @@ type Store[R Resource, Q Query, P PKey] interface {
 	List(context.Context, *Q) ([]R, error)
 }
 
+// Patcher is implemented by stores which can update a subset of the fields of a resource natively.
+// fields holds the Go field names of r which are to be written, the other fields of r are those of the stored resource.
+type Patcher[R Resource, P PKey] interface {
+	Patch(ctx context.Context, pkey P, r *R, fields []string) error
+}
+
+// ConditionalStore is implemented by stores which can update and delete a resource
+// only if its version is the expected one, atomically, see VersionField.
+// They return ErrPreconditionFailed if the version of the stored resource is another one.
+type ConditionalStore[R Resource, P PKey] interface {
+	UpdateIf(ctx context.Context, pkey P, r *R, version string) error
+	DeleteIf(ctx context.Context, pkey P, version string) error
+}
+
+// Streamer is implemented by stores which can list resources without loading all of them into memory.
+// Stream calls yield with each resource which matches q, and stops at the first error yield returns.
+type Streamer[R Resource, Q Query] interface {
+	Stream(ctx context.Context, q *Q, yield func(R) error) error
+}
+
+// Counter is implemented by stores which can count the resources which match q, regardless of pagination.
+// Servers respond to GET and HEAD of the collection with the count in the X-Total-Count header, unless the list is streamed,
+// so Count should be cheaper than List. Count may return ErrNotImplemented to skip the header.
+type Counter[Q Query] interface {
+	Count(ctx context.Context, q *Q) (int, error)
+}
+
+// Operation is an operation of a Store.
+type Operation string
+
+const (
+	OperationCreate Operation = "create"
+	OperationRead   Operation = "read"
+	OperationUpdate Operation = "update"
+	OperationPatch  Operation = "patch"
+	OperationDelete Operation = "delete"
+	OperationList   Operation = "list"
+)
+
+// AllOperations are the operations of a Store.
+var AllOperations = []Operation{OperationCreate, OperationRead, OperationUpdate, OperationPatch, OperationDelete, OperationList}
+
+// Operator is implemented by stores which support only some of the operations, such as read only stores,
+// and by Servers, which support the operations of their stores.
+// The methods of the other operations are never called by DefaultMux, which responds with 405 Method Not Allowed instead.
+type Operator interface {
+	Operations() []Operation
+}
+
+// Operations returns the operations of the first store which is an Operator, among store and the stores it wraps,
+// or AllOperations if there is none.
+func Operations[R Resource, Q Query, P PKey](store Store[R, Q, P]) []Operation {
+	for {
+		if o, ok := store.(Operator); ok {
+			return o.Operations()
+		}
+		u, ok := store.(Unwrapper[R, Q, P])
+		if !ok {
+			return AllOperations
+		}
+		store = u.Unwrap()
+	}
+}
+
+// Unwrapper is implemented by stores which wrap another store.
+type Unwrapper[R Resource, Q Query, P PKey] interface {
+	Unwrap() Store[R, Q, P]
+}
+
+// Supports reports whether store and all the stores it wraps implement the optional interface T.
+func Supports[T any, R Resource, Q Query, P PKey](store Store[R, Q, P]) bool {
+	for {
+		if _, ok := store.(T); !ok {
+			return false
+		}
+		u, ok := store.(Unwrapper[R, Q, P])
+		if !ok {
+			return true
+		}
+		store = u.Unwrap()
+	}
+}
+
 type mapIntStore[R Resource, Q Query, P PUintKey] struct {
 	mapStore[R, Q, P]
 	nextID P
@@ -29,7 +113,12 @@ func NewMapStore[R Resource, Q Query, P PUintKey](r R, q Q, p P) Store[R, Q, P]
 }
 
 func (s *mapIntStore[R, Q, P]) Create(ctx context.Context, r *R) error {
-	s.m[s.nextID] = r
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i, ok := any(r).(Identifiable[P]); ok {
+		i.SetPKey(s.nextID)
+	}
+	s.add(s.nextID, r)
 	s.nextID++
 	return nil
 }
@@ -49,47 +138,173 @@ func NewMapStrStore[R Resource, Q Query, P PStrKey](r R, q Q, p P) Store[R, Q, P
 }
 
 func (s *mapStrStore[R, Q, P]) Create(ctx context.Context, r *R) error {
-	s.m[s.nextID] = r
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	i, err := strconv.ParseInt(string(s.nextID), 10, 64)
 	if err != nil {
 		return err
 	}
+	if id, ok := any(r).(Identifiable[P]); ok {
+		id.SetPKey(s.nextID)
+	}
+	s.add(s.nextID, r)
 	s.nextID = P(fmt.Sprintf("%d", i+1))
 	return nil
 }
 
+// mapStore is safe for concurrent use.
+// It stores and returns copies of the resources, so that callers can't modify the stored resources concurrently.
+// The copies are shallow, maps and slices in the resources are shared.
+// Resources of another parent than that of the request are served as if they don't exist, see InParent.
 type mapStore[R Resource, Q Query, P PKey] struct {
-	m map[P]*R
+	mu sync.RWMutex
+	m  map[P]*R
+	// keys holds the keys in insertion order
+	keys []P
+}
+
+// add must be called with mu locked.
+func (s *mapStore[R, Q, P]) add(pkey P, r *R) {
+	if v, ok := any(r).(VersionField); ok {
+		v.SetVersion(1)
+	}
+	rr := *r
+	s.m[pkey] = &rr
+	s.keys = append(s.keys, pkey)
 }
 
 func (s *mapStore[R, Q, P]) Read(ctx context.Context, pkey P, q *Q) (*R, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	r, ok := s.m[pkey]
-	if !ok {
-		return r, ErrNotFound
+	if !ok || !InParent(ctx, r) {
+		return nil, ErrNotFound
 	}
-	return r, nil
+	rr := *r
+	return &rr, nil
 }
 
 func (s *mapStore[R, Q, P]) Update(ctx context.Context, pkey P, r *R) error {
-	_, ok := s.m[pkey]
-	if !ok {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.m[pkey]
+	if !ok || !InParent(ctx, cur) {
 		return ErrNotFound
 	}
-	s.m[pkey] = r
+	s.update(pkey, cur, r)
 	return nil
 }
 
+// UpdateIf updates the resource only if its version is version.
+func (s *mapStore[R, Q, P]) UpdateIf(ctx context.Context, pkey P, r *R, version string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.m[pkey]
+	if !ok || !InParent(ctx, cur) {
+		return ErrNotFound
+	}
+	if v, ok := any(cur).(Versioned); !ok || v.Version() != version {
+		return ErrPreconditionFailed
+	}
+	s.update(pkey, cur, r)
+	return nil
+}
+
+// update replaces cur with r. It must be called with mu locked.
+func (s *mapStore[R, Q, P]) update(pkey P, cur *R, r *R) {
+	if i, ok := any(r).(Identifiable[P]); ok {
+		i.SetPKey(pkey)
+	}
+	if v, ok := any(r).(VersionField); ok {
+		v.SetVersion(nextVersion(any(cur).(Versioned)))
+	}
+	rr := *r
+	s.m[pkey] = &rr
+}
+
+// nextVersion returns the version which follows the version of v, or 1 if it isn't a counter.
+func nextVersion(v Versioned) uint64 {
+	i, err := strconv.ParseUint(v.Version(), 10, 64)
+	if err != nil {
+		return 1
+	}
+	return i + 1
+}
+
 func (s *mapStore[R, Q, P]) Delete(ctx context.Context, pkey P) error {
-	delete(s.m, pkey)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur, ok := s.m[pkey]; !ok || !InParent(ctx, cur) {
+		return ErrNotFound
+	}
+	s.remove(pkey)
 	return nil
 }
 
+// DeleteIf deletes the resource only if its version is version.
+func (s *mapStore[R, Q, P]) DeleteIf(ctx context.Context, pkey P, version string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.m[pkey]
+	if !ok || !InParent(ctx, cur) {
+		return ErrNotFound
+	}
+	if v, ok := any(cur).(Versioned); !ok || v.Version() != version {
+		return ErrPreconditionFailed
+	}
+	s.remove(pkey)
+	return nil
+}
+
+// remove must be called with mu locked.
+func (s *mapStore[R, Q, P]) remove(pkey P) {
+	delete(s.m, pkey)
+	for i, k := range s.keys {
+		if k == pkey {
+			s.keys = append(s.keys[:i], s.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// List returns the resources of the parent of ctx which match the conditions of q in insertion order,
+// unless q has a sort field. See filter for how conditions are described.
 func (s *mapStore[R, Q, P]) List(ctx context.Context, q *Q) ([]R, error) {
-	var r []R
-	for _, v := range s.m {
-		r = append(r, *v)
+	s.mu.RLock()
+	r := make([]R, 0, len(s.keys))
+	for _, k := range s.keys {
+		if InParent(ctx, s.m[k]) {
+			r = append(r, *s.m[k])
+		}
+	}
+	s.mu.RUnlock()
+	return filter(r, q)
+}
+
+func (s *mapStore[R, Q, P]) ListPage(ctx context.Context, q *Q, page Page) ([]R, string, error) {
+	r, err := s.List(ctx, q)
+	if err != nil {
+		return nil, "", err
 	}
-	return r, nil
+	return PageOf(r, page)
+}
+
+func (s *mapStore[R, Q, P]) Count(ctx context.Context, q *Q) (int, error) {
+	r, err := s.List(ctx, q)
+	return len(r), err
+}
+
+func (s *mapStore[R, Q, P]) Stream(ctx context.Context, q *Q, yield func(R) error) error {
+	r, err := s.List(ctx, q)
+	if err != nil {
+		return err
+	}
+	for _, rr := range r {
+		if err := yield(rr); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 type hookStore[R Resource, Q Query, P PKey] struct {
@@ -102,6 +317,10 @@ func NewHookStore[R Resource, Q Query, P PKey](store Store[R, Q, P]) Store[R, Q,
 	}
 }
 
+func (s hookStore[R, Q, P]) Unwrap() Store[R, Q, P] {
+	return s.store
+}
+
 type BeforeCreate interface {
 	BeforeCreate(context.Context) error
 }
@@ -179,6 +398,51 @@ func (s hookStore[R, Q, P]) Update(ctx context.Context, pkey P, r *R) error {
 	return nil
 }
 
+// Patch calls the BeforeUpdate and AfterUpdate hooks around the wrapped store's Patch.
+// It returns ErrNotImplemented if the wrapped store is not a Patcher.
+func (s hookStore[R, Q, P]) Patch(ctx context.Context, pkey P, r *R, fields []string) error {
+	if !Supports[Patcher[R, P]](s.store) {
+		return ErrNotImplemented
+	}
+	p := s.store.(Patcher[R, P])
+	if h, ok := any(r).(BeforeUpdate[P]); ok {
+		if err := h.BeforeUpdate(ctx, pkey); err != nil {
+			return err
+		}
+	}
+	if err := p.Patch(ctx, pkey, r, fields); err != nil {
+		return err
+	}
+	if h, ok := any(r).(AfterUpdate[P]); ok {
+		if err := h.AfterUpdate(ctx, pkey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateIf calls the BeforeUpdate and AfterUpdate hooks around the wrapped store's UpdateIf.
+// It returns ErrNotImplemented if the wrapped store is not a ConditionalStore.
+func (s hookStore[R, Q, P]) UpdateIf(ctx context.Context, pkey P, r *R, version string) error {
+	if !Supports[ConditionalStore[R, P]](s.store) {
+		return ErrNotImplemented
+	}
+	if h, ok := any(r).(BeforeUpdate[P]); ok {
+		if err := h.BeforeUpdate(ctx, pkey); err != nil {
+			return err
+		}
+	}
+	if err := s.store.(ConditionalStore[R, P]).UpdateIf(ctx, pkey, r, version); err != nil {
+		return err
+	}
+	if h, ok := any(r).(AfterUpdate[P]); ok {
+		if err := h.AfterUpdate(ctx, pkey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type BeforeDelete[P PKey] interface {
 	BeforeDelete(context.Context, P) error
 }
@@ -205,6 +469,29 @@ func (s hookStore[R, Q, P]) Delete(ctx context.Context, pkey P) error {
 	return nil
 }
 
+// DeleteIf calls the BeforeDelete and AfterDelete hooks around the wrapped store's DeleteIf.
+// It returns ErrNotImplemented if the wrapped store is not a ConditionalStore.
+func (s hookStore[R, Q, P]) DeleteIf(ctx context.Context, pkey P, version string) error {
+	if !Supports[ConditionalStore[R, P]](s.store) {
+		return ErrNotImplemented
+	}
+	var r R
+	if h, ok := any(&r).(BeforeDelete[P]); ok {
+		if err := h.BeforeDelete(ctx, pkey); err != nil {
+			return err
+		}
+	}
+	if err := s.store.(ConditionalStore[R, P]).DeleteIf(ctx, pkey, version); err != nil {
+		return err
+	}
+	if h, ok := any(&r).(AfterDelete[P]); ok {
+		if err := h.AfterDelete(ctx, pkey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type BeforeList[Q Query] interface {
 	BeforeList(context.Context, *Q) error
 }
@@ -231,3 +518,95 @@ func (s hookStore[R, Q, P]) List(ctx context.Context, q *Q) ([]R, error) {
 	}
 	return l, nil
 }
+
+// ListPage calls the BeforeList and AfterList hooks around the wrapped store's ListPage.
+// It returns ErrNotImplemented if the wrapped store is not a PagedStore.
+func (s hookStore[R, Q, P]) ListPage(ctx context.Context, q *Q, page Page) ([]R, string, error) {
+	if !Supports[PagedStore[R, Q]](s.store) {
+		return nil, "", ErrNotImplemented
+	}
+	var r R
+	if h, ok := any(&r).(BeforeList[Q]); ok {
+		if err := h.BeforeList(ctx, q); err != nil {
+			return nil, "", err
+		}
+	}
+	l, next, err := s.store.(PagedStore[R, Q]).ListPage(ctx, q, page)
+	if err != nil {
+		return l, "", err
+	}
+	if h, ok := any(&r).(AfterList[R, Q]); ok {
+		if err := h.AfterList(ctx, q, l); err != nil {
+			return nil, "", err
+		}
+	}
+	return l, next, nil
+}
+
+type AfterStream[R Resource, Q Query] interface {
+	AfterStream(context.Context, *Q, *R) error
+}
+
+// Stream calls the BeforeList hook before the wrapped store's Stream, and the AfterStream hook with each resource before it is yielded.
+// Resources which implement AfterList but not AfterStream are listed with List instead, so that AfterList sees the whole list,
+// and then yielded.
+// It returns ErrNotImplemented if the wrapped store is not a Streamer.
+func (s hookStore[R, Q, P]) Stream(ctx context.Context, q *Q, yield func(R) error) error {
+	if !Supports[Streamer[R, Q]](s.store) {
+		return ErrNotImplemented
+	}
+	var r R
+	h, after := any(&r).(AfterStream[R, Q])
+	if _, ok := any(&r).(AfterList[R, Q]); ok && !after {
+		l, err := s.List(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, rr := range l {
+			if err := yield(rr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if h, ok := any(&r).(BeforeList[Q]); ok {
+		if err := h.BeforeList(ctx, q); err != nil {
+			return err
+		}
+	}
+	return s.store.(Streamer[R, Q]).Stream(ctx, q, func(rr R) error {
+		if after {
+			if err := h.AfterStream(ctx, q, &rr); err != nil {
+				return err
+			}
+		}
+		return yield(rr)
+	})
+}
+
+// Count calls the BeforeList hook before the wrapped store's Count,
+// unless q was already passed to it by List for the same request, see withListed.
+// It returns ErrNotImplemented if the wrapped store is not a Counter.
+func (s hookStore[R, Q, P]) Count(ctx context.Context, q *Q) (int, error) {
+	if !Supports[Counter[Q]](s.store) {
+		return 0, ErrNotImplemented
+	}
+	var r R
+	if h, ok := any(&r).(BeforeList[Q]); ok && !listed(ctx) {
+		if err := h.BeforeList(ctx, q); err != nil {
+			return 0, err
+		}
+	}
+	return s.store.(Counter[Q]).Count(ctx, q)
+}
+
+type listedKey struct{}
+
+// withListed marks the query of ctx as passed to the BeforeList hook, so that Count doesn't pass it again.
+func withListed(ctx context.Context) context.Context {
+	return context.WithValue(ctx, listedKey{}, true)
+}
+
+func listed(ctx context.Context) bool {
+	return ctx.Value(listedKey{}) != nil
+}
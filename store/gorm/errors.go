@@ -0,0 +1,69 @@
+package gorm
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/mash/ghost"
+	"gorm.io/gorm"
+)
+
+// ConstraintError is the Err of the ghost.Error returned when a write violates a constraint of the database.
+// Its message names the kind of the constraint only, the error of the database driver is Unwrapped.
+type ConstraintError struct {
+	// Constraint is "unique" or "foreign key".
+	Constraint string
+	Err        error
+}
+
+func (e ConstraintError) Error() string {
+	return e.Constraint + " constraint violation"
+}
+
+func (e ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// sqlStater is implemented by the errors of PostgreSQL drivers.
+type sqlStater interface {
+	SQLState() string
+}
+
+// translateError translates errors of gorm and the database drivers into ghost.Errors.
+// gorm.ErrRecordNotFound is ghost.ErrNotFound, a unique constraint violation is 409 Conflict,
+// and a foreign key constraint violation is 409 Conflict when deleting a referenced row,
+// or 422 Unprocessable Entity when referencing a missing row.
+// The drivers are told apart by their error messages and SQLSTATE codes.
+func translateError(err error, deleting bool) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ghost.ErrNotFound
+	}
+	var state string
+	var s sqlStater
+	if errors.As(err, &s) {
+		state = s.SQLState()
+	}
+	msg := err.Error()
+	switch {
+	case state == "23505",
+		strings.Contains(msg, "UNIQUE constraint failed"),            // sqlite
+		strings.Contains(msg, "Error 1062"),                          // mysql
+		strings.Contains(msg, "duplicate key value violates unique"): // postgres
+		return ghost.Error{Code: http.StatusConflict, Err: ConstraintError{Constraint: "unique", Err: err}}
+	case state == "23503",
+		strings.Contains(msg, "FOREIGN KEY constraint failed"),   // sqlite
+		strings.Contains(msg, "Error 1451"),                      // mysql, deleting
+		strings.Contains(msg, "Error 1452"),                      // mysql, referencing
+		strings.Contains(msg, "violates foreign key constraint"): // postgres
+		code := http.StatusUnprocessableEntity
+		if deleting {
+			code = http.StatusConflict
+		}
+		return ghost.Error{Code: code, Err: ConstraintError{Constraint: "foreign key", Err: err}}
+	}
+	return err
+}
@@ -2,9 +2,14 @@ package gorm
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"strconv"
 
 	"github.com/mash/ghost"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 type gormStore[R ghost.Resource, Q ghost.Query, P ghost.PKey] struct {
@@ -22,12 +27,15 @@ type Create interface {
 }
 
 func (s gormStore[R, Q, P]) Create(ctx context.Context, r *R) error {
+	if v, ok := any(r).(ghost.VersionField); ok {
+		v.SetVersion(1)
+	}
 	if rr, ok := any(r).(Create); ok {
-		return rr.Create(ctx, s.db)
+		return translateError(rr.Create(ctx, s.db), false)
 	}
 
 	result := s.db.Create(&r)
-	return result.Error
+	return translateError(result.Error, false)
 }
 
 type Read[R ghost.Resource, Q ghost.Query, P ghost.PKey] interface {
@@ -37,57 +45,414 @@ type Read[R ghost.Resource, Q ghost.Query, P ghost.PKey] interface {
 func (s gormStore[R, Q, P]) Read(ctx context.Context, pkey P, q *Q) (*R, error) {
 	var r R
 	if rr, ok := any(&r).(Read[R, Q, P]); ok {
-		return rr.Read(ctx, s.db, pkey, q)
+		res, err := rr.Read(ctx, s.db, pkey, q)
+		return res, translateError(err, false)
+	}
+
+	if err := s.first(ctx, &r, pkey, false); err != nil {
+		return nil, err
 	}
+	return &r, nil
+}
+
+// inParent adds the condition of the parent of ctx to tx, see ghost.InParent.
+// The ParentKey of a zero R is set to the parent, and it is the condition, which ignores the other, zero, fields.
+func inParent[R ghost.Resource](ctx context.Context, tx *gorm.DB) *gorm.DB {
+	var parent R
+	ghost.SetParent(ctx, &parent)
+	if reflect.ValueOf(&parent).Elem().IsZero() {
+		return tx
+	}
+	return tx.Where(&parent)
+}
 
-	result := s.db.First(&r, pkey)
-	return &r, result.Error
+// first reads the row of pkey into r.
+// Rows of another parent than that of ctx are ghost.ErrNotFound, see ghost.InParent.
+func (s gormStore[R, Q, P]) first(ctx context.Context, r *R, pkey P, delete bool) error {
+	if err := s.db.First(r, pkey).Error; err != nil {
+		return translateError(err, delete)
+	}
+	if !ghost.InParent(ctx, r) {
+		return ghost.ErrNotFound
+	}
+	return nil
 }
 
 type Update[P ghost.PKey] interface {
 	Update(context.Context, *gorm.DB, P) error
 }
 
+// Update returns ghost.ErrNotFound if there is no row with pkey.
 func (s gormStore[R, Q, P]) Update(ctx context.Context, pkey P, r *R) error {
 	if rr, ok := any(r).(Update[P]); ok {
-		return rr.Update(ctx, s.db, pkey)
+		return translateError(rr.Update(ctx, s.db, pkey), false)
 	}
 
 	var orig R
-	result := s.db.Find(&orig, pkey)
+	if err := s.first(ctx, &orig, pkey, false); err != nil {
+		return err
+	}
+	if v, ok := any(r).(ghost.VersionField); ok {
+		v.SetVersion(nextVersion(&orig))
+	}
+
+	// the row exists, so no rows affected only means nothing changed
+	result := s.db.Model(&orig).Updates(&r)
+	return translateError(result.Error, false)
+}
+
+type Patch[P ghost.PKey] interface {
+	Patch(context.Context, *gorm.DB, P, []string) error
+}
+
+// Patch updates only the fields in the patch.
+// Resources which implement Update but not Patch are read, patched and passed to their Update instead.
+func (s gormStore[R, Q, P]) Patch(ctx context.Context, pkey P, r *R, fields []string) error {
+	if rr, ok := any(r).(Patch[P]); ok {
+		return translateError(rr.Patch(ctx, s.db, pkey, fields), false)
+	}
+
+	var orig R
+	if err := s.first(ctx, &orig, pkey, false); err != nil {
+		return err
+	}
+
+	if rr, ok := any(&orig).(Update[P]); ok {
+		src, dst := reflect.ValueOf(r).Elem(), reflect.ValueOf(&orig).Elem()
+		for _, f := range fields {
+			dst.FieldByName(f).Set(src.FieldByName(f))
+		}
+		return translateError(rr.Update(ctx, s.db, pkey), false)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	if v, ok := any(r).(ghost.VersionField); ok {
+		f, err := s.versionField()
+		if err != nil {
+			return err
+		}
+		v.SetVersion(nextVersion(&orig))
+		fields = append(fields, f.Name)
+	}
+
+	result := s.db.Model(&orig).Select(fields).Updates(r)
+	return translateError(result.Error, false)
+}
+
+// UpdateIf updates the resource only if its version is version, with UPDATE ... WHERE version = ?,
+// if R is a ghost.VersionField whose column is named version.
+// Otherwise, and for resources which implement Update, the version is checked before updating, which isn't atomic.
+func (s gormStore[R, Q, P]) UpdateIf(ctx context.Context, pkey P, r *R, version string) error {
+	var orig R
+	if err := s.first(ctx, &orig, pkey, false); err != nil {
+		return err
+	}
+	if !versionIs(&orig, version) {
+		return ghost.ErrPreconditionFailed
+	}
+	v, counter := any(r).(ghost.VersionField)
+	if _, ok := any(r).(Update[P]); ok || !counter {
+		return s.Update(ctx, pkey, r)
+	}
+
+	f, err := s.versionField()
+	if err != nil {
+		return err
+	}
+	prev, err := strconv.ParseUint(version, 10, 64)
+	if err != nil {
+		return ghost.ErrPreconditionFailed
+	}
+	v.SetVersion(prev + 1)
+	result := s.db.Model(&orig).Where(clause.Eq{Column: clause.Column{Name: f.DBName}, Value: prev}).Updates(r)
 	if result.Error != nil {
-		return result.Error
+		return translateError(result.Error, false)
 	}
+	if result.RowsAffected == 0 {
+		// updated since read
+		return ghost.ErrPreconditionFailed
+	}
+	return nil
+}
+
+// versionField returns the field of the version of R, whose column is named version.
+// The field can't be named Version, which is the method of ghost.Versioned.
+func (s gormStore[R, Q, P]) versionField() (*schema.Field, error) {
+	var r R
+	stmt := &gorm.Statement{DB: s.db}
+	if err := stmt.Parse(&r); err != nil {
+		return nil, err
+	}
+	f := stmt.Schema.LookUpField("version")
+	if f == nil {
+		return nil, fmt.Errorf("%s has no version column", stmt.Schema.Name)
+	}
+	return f, nil
+}
 
-	result = s.db.Model(&orig).Updates(&r)
-	return result.Error
+// versionIs reports whether r is ghost.Versioned and its version is version.
+func versionIs[R ghost.Resource](r *R, version string) bool {
+	v, ok := any(r).(ghost.Versioned)
+	return ok && v.Version() == version
+}
+
+// nextVersion returns the version which follows the version of r, or 1 if it isn't a counter.
+func nextVersion[R ghost.Resource](r *R) uint64 {
+	v, ok := any(r).(ghost.Versioned)
+	if !ok {
+		return 1
+	}
+	i, err := strconv.ParseUint(v.Version(), 10, 64)
+	if err != nil {
+		return 1
+	}
+	return i + 1
 }
 
 type Delete[P ghost.PKey] interface {
 	Delete(context.Context, *gorm.DB, P) error
 }
 
+// Delete returns ghost.ErrNotFound if no row is deleted.
+// The row is read first, so that rows of another parent aren't deleted, see ghost.InParent.
 func (s gormStore[R, Q, P]) Delete(ctx context.Context, pkey P) error {
 	var r R
 	if rr, ok := any(&r).(Delete[P]); ok {
-		return rr.Delete(ctx, s.db, pkey)
+		return translateError(rr.Delete(ctx, s.db, pkey), true)
 	}
 
+	if err := s.first(ctx, &r, pkey, true); err != nil {
+		return err
+	}
 	result := s.db.Delete(&r, pkey)
-	return result.Error
+	if result.Error != nil {
+		return translateError(result.Error, true)
+	}
+	if result.RowsAffected == 0 {
+		return ghost.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteIf deletes the resource only if its version is version, like UpdateIf.
+func (s gormStore[R, Q, P]) DeleteIf(ctx context.Context, pkey P, version string) error {
+	var r R
+	_, counter := any(&r).(ghost.VersionField)
+	if err := s.first(ctx, &r, pkey, true); err != nil {
+		return err
+	}
+	if _, ok := any(&r).(Delete[P]); ok || !counter {
+		if !versionIs(&r, version) {
+			return ghost.ErrPreconditionFailed
+		}
+		return s.Delete(ctx, pkey)
+	}
+
+	f, err := s.versionField()
+	if err != nil {
+		return err
+	}
+	prev, err := strconv.ParseUint(version, 10, 64)
+	if err != nil {
+		return ghost.ErrPreconditionFailed
+	}
+	result := s.db.Where(clause.Eq{Column: clause.Column{Name: f.DBName}, Value: prev}).Delete(&r, pkey)
+	if result.Error != nil {
+		return translateError(result.Error, true)
+	}
+	if result.RowsAffected == 0 {
+		// tell a missing resource from another version
+		if result := s.db.First(&r, pkey); result.Error != nil {
+			return translateError(result.Error, true)
+		}
+		return ghost.ErrPreconditionFailed
+	}
+	return nil
 }
 
 type List[R ghost.Resource, Q ghost.Query] interface {
 	List(context.Context, *gorm.DB, *Q) ([]R, error)
 }
 
+// List lists the resources of the parent of ctx which match the conditions of q, see Where and ghost.InParent.
+// They are in the order of the sort fields of q, see Order, and then of the primary key, descending.
 func (s gormStore[R, Q, P]) List(ctx context.Context, q *Q) ([]R, error) {
 	var r R
 	if rp, ok := any(&r).(List[R, Q]); ok {
-		return rp.List(ctx, s.db, q)
+		rr, err := rp.List(ctx, s.db, q)
+		return rr, translateError(err, false)
 	}
 
+	tx, _, _, err := s.query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
 	var rr []R
-	result := s.db.Order("id desc").Find(&rr)
-	return rr, result.Error
+	result := tx.Find(&rr)
+	return rr, translateError(result.Error, false)
+}
+
+// query returns the statement which lists the resources in the order of List, the primary key,
+// and whether q has a sort field.
+func (s gormStore[R, Q, P]) query(ctx context.Context, q *Q) (*gorm.DB, *schema.Field, bool, error) {
+	var r R
+	stmt := &gorm.Statement{DB: s.db}
+	if err := stmt.Parse(&r); err != nil {
+		return nil, nil, false, err
+	}
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return nil, nil, false, fmt.Errorf("%s has no primary key", stmt.Schema.Name)
+	}
+
+	db := s.db.WithContext(ctx)
+	order, err := Order(db, &r, q)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	tx, err := Where(db.Model(&r), q)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	tx = inParent[R](ctx, tx)
+	columns := append(order, clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: pk.DBName}, Desc: true})
+	return tx.Clauses(clause.OrderBy{Columns: columns}), pk, len(order) > 0, nil
+}
+
+type ListPage[R ghost.Resource, Q ghost.Query] interface {
+	ListPage(context.Context, *gorm.DB, *Q, ghost.Page) ([]R, string, error)
+}
+
+// ListPage paginates with keyset pagination on the primary key, descending like List.
+// The cursor is the primary key of the last resource in the page.
+// Sorted lists aren't in the order of the primary key, so they are paginated by offset, with the offset as the cursor.
+// Resources which implement List but not ListPage are paginated in memory instead.
+func (s gormStore[R, Q, P]) ListPage(ctx context.Context, q *Q, page ghost.Page) ([]R, string, error) {
+	var r R
+	if rp, ok := any(&r).(ListPage[R, Q]); ok {
+		rr, next, err := rp.ListPage(ctx, s.db, q, page)
+		return rr, next, translateError(err, false)
+	}
+	if rp, ok := any(&r).(List[R, Q]); ok {
+		rr, err := rp.List(ctx, s.db, q)
+		if err != nil {
+			return nil, "", translateError(err, false)
+		}
+		return ghost.PageOf(rr, page)
+	}
+
+	tx, pk, sorted, err := s.query(ctx, q)
+	if err != nil {
+		return nil, "", err
+	}
+	offset := page.Offset
+	if sorted && page.Cursor != "" {
+		var start int
+		if err := ghost.DecodeCursor(page.Cursor, &start); err != nil || start < 0 {
+			return nil, "", ghost.ErrInvalidCursor
+		}
+		offset += start
+	} else if page.Cursor != "" {
+		last := reflect.New(pk.FieldType)
+		if err := ghost.DecodeCursor(page.Cursor, last.Interface()); err != nil {
+			return nil, "", err
+		}
+		tx = tx.Where(clause.Lt{Column: clause.Column{Table: clause.CurrentTable, Name: pk.DBName}, Value: last.Elem().Interface()})
+	}
+	if offset > 0 {
+		tx = tx.Offset(offset)
+	}
+	if page.Limit > 0 {
+		// fetch one more to know if there is a next page
+		tx = tx.Limit(page.Limit + 1)
+	}
+
+	rr := []R{}
+	if result := tx.Find(&rr); result.Error != nil {
+		return nil, "", translateError(result.Error, false)
+	}
+	if page.Limit == 0 || len(rr) <= page.Limit {
+		return rr, "", nil
+	}
+	rr = rr[:page.Limit]
+	if sorted {
+		next, err := ghost.EncodeCursor(offset + page.Limit)
+		return rr, next, err
+	}
+	last, _ := pk.ValueOf(ctx, reflect.ValueOf(&rr[page.Limit-1]).Elem())
+	next, err := ghost.EncodeCursor(last)
+	return rr, next, err
+}
+
+type Count[Q ghost.Query] interface {
+	Count(context.Context, *gorm.DB, *Q) (int, error)
+}
+
+// Count counts the rows which match q.
+// Resources which implement List but not Count aren't counted, Count returns ghost.ErrNotImplemented,
+// so that servers don't list them twice.
+func (s gormStore[R, Q, P]) Count(ctx context.Context, q *Q) (int, error) {
+	var r R
+	if rp, ok := any(&r).(Count[Q]); ok {
+		n, err := rp.Count(ctx, s.db, q)
+		return n, translateError(err, false)
+	}
+	if _, ok := any(&r).(List[R, Q]); ok {
+		return 0, ghost.ErrNotImplemented
+	}
+
+	tx, err := Where(s.db.WithContext(ctx).Model(&r), q)
+	if err != nil {
+		return 0, err
+	}
+	tx = inParent[R](ctx, tx)
+	var n int64
+	result := tx.Model(&r).Count(&n)
+	return int(n), translateError(result.Error, false)
+}
+
+type Stream[R ghost.Resource, Q ghost.Query] interface {
+	Stream(context.Context, *gorm.DB, *Q, func(R) error) error
+}
+
+// Stream reads the resources row by row, in the order of List.
+// Resources which implement List but not Stream are read into memory by their List.
+func (s gormStore[R, Q, P]) Stream(ctx context.Context, q *Q, yield func(R) error) error {
+	var r R
+	if rp, ok := any(&r).(Stream[R, Q]); ok {
+		return rp.Stream(ctx, s.db, q, yield)
+	}
+	if rp, ok := any(&r).(List[R, Q]); ok {
+		rr, err := rp.List(ctx, s.db, q)
+		if err != nil {
+			return err
+		}
+		for _, r := range rr {
+			if err := yield(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tx, _, _, err := s.query(ctx, q)
+	if err != nil {
+		return err
+	}
+	rows, err := tx.Model(&r).Rows()
+	if err != nil {
+		return translateError(err, false)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r R
+		if err := tx.ScanRows(rows, &r); err != nil {
+			return err
+		}
+		if err := yield(r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
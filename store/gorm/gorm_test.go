@@ -4,10 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -92,6 +95,18 @@ func TestHttp(t *testing.T) {
 				}
 				testUser(t, e, resBody)
 			},
+		}, {
+			name:         "PATCH /1",
+			method:       "PATCH",
+			path:         "/1",
+			reqBody:      `{"Name":"Alice"}`,
+			expectedCode: 200,
+			testResBody: func(t *testing.T, resBody io.Reader) {
+				e := User{
+					Name: "Alice",
+				}
+				testUser(t, e, resBody)
+			},
 		}, {
 			name:         "GET /",
 			method:       "GET",
@@ -100,7 +115,7 @@ func TestHttp(t *testing.T) {
 			testResBody: func(t *testing.T, resBody io.Reader) {
 				e := []User{
 					{
-						Name: "Bob",
+						Name: "Alice",
 					},
 				}
 				g := []User{}
@@ -117,18 +132,14 @@ func TestHttp(t *testing.T) {
 			path:         "/1",
 			expectedCode: 204,
 			testResBody: func(t *testing.T, resBody io.Reader) {
-				e := map[string]any{}
-				g := map[string]any{}
-				if err := json.NewDecoder(resBody).Decode(&g); err != nil {
-					t.Errorf("failed to decode json body: %v", err)
-				}
-				if diff := cmp.Diff(e, g); diff != "" {
-					t.Errorf("unexpected response body (-expected +got):\n%s", diff)
+				b, _ := io.ReadAll(resBody)
+				if len(b) != 0 {
+					t.Errorf("expected an empty body, got %s", b)
 				}
 			},
 		}, {
-			name:         "PATCH /1",
-			method:       "PATCH",
+			name:         "TRACE /1",
+			method:       "TRACE",
 			path:         "/1",
 			expectedCode: 405,
 			testResBody: func(t *testing.T, resBody io.Reader) {
@@ -298,6 +309,21 @@ func TestHook(t *testing.T) {
 				}
 				testUser(t, e, resBody)
 			},
+		}, {
+			name:         "PATCH /1",
+			method:       "PATCH",
+			path:         "/1",
+			reqBody:      `{"Name":"Alice"}`,
+			expectedCode: 200,
+			testResBody: func(t *testing.T, resBody io.Reader) {
+				e := HookedUser{
+					Name: "Alice",
+					Called: map[string]int{
+						"Read": 1,
+					},
+				}
+				testUser(t, e, resBody)
+			},
 		}, {
 			name:         "GET /",
 			method:       "GET",
@@ -306,7 +332,7 @@ func TestHook(t *testing.T) {
 			testResBody: func(t *testing.T, resBody io.Reader) {
 				e := []HookedUser{
 					{
-						Name: "Bob",
+						Name: "Alice",
 					},
 				}
 				g := []HookedUser{}
@@ -323,18 +349,14 @@ func TestHook(t *testing.T) {
 			path:         "/1",
 			expectedCode: 204,
 			testResBody: func(t *testing.T, resBody io.Reader) {
-				e := map[string]any{}
-				g := map[string]any{}
-				if err := json.NewDecoder(resBody).Decode(&g); err != nil {
-					t.Errorf("failed to decode json body: %v", err)
-				}
-				if diff := cmp.Diff(e, g); diff != "" {
-					t.Errorf("unexpected response body (-expected +got):\n%s", diff)
+				b, _ := io.ReadAll(resBody)
+				if len(b) != 0 {
+					t.Errorf("expected an empty body, got %s", b)
 				}
 			},
 		}, {
-			name:         "PATCH /1",
-			method:       "PATCH",
+			name:         "TRACE /1",
+			method:       "TRACE",
 			path:         "/1",
 			expectedCode: 405,
 			testResBody: func(t *testing.T, resBody io.Reader) {
@@ -374,3 +396,730 @@ func TestHook(t *testing.T) {
 		t.Errorf("unexpected calls to hooks (-want +got):\n%s", diff)
 	}
 }
+
+func TestPagination(t *testing.T) {
+	_ = os.Remove("page.db")
+	db, err := gorm.Open(sqlite.Open("page.db"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	// create the table
+	db.AutoMigrate(&User{})
+
+	store := ggorm.NewStore(User{}, SearchQuery{}, uint64(0), db)
+	g := ghost.New(store)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(`{"Name":"`+name+`"}`)))
+		if e, g := 201, w.Code; e != g {
+			t.Fatalf("expected %d, got %d", e, g)
+		}
+	}
+
+	var pages [][]string
+	path := "/?limit=2"
+	for path != "" {
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if e, g := 200, w.Code; e != g {
+			t.Fatalf("expected %d, got %d, body: %s", e, g, w.Body.String())
+		}
+		var users []User
+		if err := json.NewDecoder(w.Body).Decode(&users); err != nil {
+			t.Fatalf("failed to decode json body: %v", err)
+		}
+		var names []string
+		for _, u := range users {
+			names = append(names, u.Name)
+		}
+		pages = append(pages, names)
+
+		path = ""
+		if link := w.Header().Get("Link"); link != "" {
+			path = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+		}
+	}
+	if diff := cmp.Diff([][]string{{"e", "d"}, {"c", "b"}, {"a"}}, pages); diff != "" {
+		t.Errorf("unexpected pages (-want +got):\n%s", diff)
+	}
+}
+
+type Product struct {
+	gorm.Model
+	Name     string
+	Price    int
+	Category string
+}
+
+type ProductQuery struct {
+	Name       string   `where:"name,like"`
+	MinPrice   int      `where:"price,gte"`
+	MaxPrice   *int     `where:"price,lte"`
+	Categories []string `schema:"category" where:"category"`
+	Ignored    string   `where:"-"`
+	Sort       string   `schema:"sort" where:",sort"`
+	// not a column of Product
+	Format string `schema:"format"`
+}
+
+func TestWhere(t *testing.T) {
+	_ = os.Remove("where.db")
+	db, err := gorm.Open(sqlite.Open("where.db"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	// create the table
+	db.AutoMigrate(&Product{})
+	db.Create([]Product{
+		{Name: "apple", Price: 0, Category: "fruit"},
+		{Name: "banana", Price: 20, Category: "fruit"},
+		{Name: "carrot", Price: 30, Category: "vegetable"},
+		{Name: "bread", Price: 40, Category: "bakery"},
+	})
+
+	store := ggorm.NewStore(Product{}, ProductQuery{}, uint64(0), db)
+	g := ghost.New(store)
+
+	tests := []struct {
+		name, path    string
+		expectedNames []string
+		// the count ignores the pagination
+		expectedCount string
+	}{
+		{
+			name:          "no conditions",
+			path:          "/?Ignored=x",
+			expectedNames: []string{"bread", "carrot", "banana", "apple"},
+			expectedCount: "4",
+		}, {
+			name:          "untagged field which isn't a column",
+			path:          "/?format=csv",
+			expectedNames: []string{"bread", "carrot", "banana", "apple"},
+			expectedCount: "4",
+		}, {
+			name:          "like",
+			path:          "/?Name=b%25",
+			expectedNames: []string{"bread", "banana"},
+			expectedCount: "2",
+		}, {
+			name:          "range",
+			path:          "/?MinPrice=20&MaxPrice=30",
+			expectedNames: []string{"carrot", "banana"},
+			expectedCount: "2",
+		}, {
+			name:          "zero pointer",
+			path:          "/?MaxPrice=0",
+			expectedNames: []string{"apple"},
+			expectedCount: "1",
+		}, {
+			name:          "in",
+			path:          "/?category=fruit&category=bakery",
+			expectedNames: []string{"bread", "banana", "apple"},
+			expectedCount: "3",
+		}, {
+			name:          "paginated",
+			path:          "/?category=fruit&limit=1",
+			expectedNames: []string{"banana"},
+			expectedCount: "2",
+		}, {
+			name:          "sort",
+			path:          "/?sort=-price",
+			expectedNames: []string{"bread", "carrot", "banana", "apple"},
+			expectedCount: "4",
+		}, {
+			name:          "sort by several fields",
+			path:          "/?sort=category,-Name",
+			expectedNames: []string{"bread", "banana", "apple", "carrot"},
+			expectedCount: "4",
+		}, {
+			name:          "sorted and paginated",
+			path:          "/?sort=-price&limit=2&offset=1",
+			expectedNames: []string{"carrot", "banana"},
+			expectedCount: "4",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+			if e, g := 200, w.Code; e != g {
+				t.Fatalf("expected %d, got %d, body: %s", e, g, w.Body.String())
+			}
+			var products []Product
+			if err := json.NewDecoder(w.Body).Decode(&products); err != nil {
+				t.Fatalf("failed to decode json body: %v", err)
+			}
+			names := []string{}
+			for _, p := range products {
+				names = append(names, p.Name)
+			}
+			if diff := cmp.Diff(test.expectedNames, names); diff != "" {
+				t.Errorf("unexpected products (-want +got):\n%s", diff)
+			}
+
+			w = httptest.NewRecorder()
+			g.ServeHTTP(w, httptest.NewRequest("HEAD", test.path, nil))
+			if e, g := test.expectedCount, w.Header().Get("X-Total-Count"); e != g {
+				t.Errorf("expected X-Total-Count %s, got %s", e, g)
+			}
+		})
+	}
+	t.Run("sorted pages", func(t *testing.T) {
+		var pages [][]string
+		path := "/?sort=-price&limit=3"
+		for path != "" {
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+			if e, g := 200, w.Code; e != g {
+				t.Fatalf("expected %d, got %d, body: %s", e, g, w.Body.String())
+			}
+			var products []Product
+			if err := json.NewDecoder(w.Body).Decode(&products); err != nil {
+				t.Fatalf("failed to decode json body: %v", err)
+			}
+			var names []string
+			for _, p := range products {
+				names = append(names, p.Name)
+			}
+			pages = append(pages, names)
+
+			path = ""
+			if link := w.Header().Get("Link"); link != "" {
+				path = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+			}
+		}
+		if diff := cmp.Diff([][]string{{"bread", "carrot", "banana"}, {"apple"}}, pages); diff != "" {
+			t.Errorf("unexpected pages (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("unknown sort key", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest("GET", "/?sort=weight", nil))
+		if e, g := 400, w.Code; e != g {
+			t.Errorf("expected %d, got %d", e, g)
+		}
+		if e, g := `{"error":"unknown sort key \"weight\""}`, strings.TrimSpace(w.Body.String()); e != g {
+			t.Errorf("expected %s, got %s", e, g)
+		}
+	})
+}
+
+func TestStream(t *testing.T) {
+	_ = os.Remove("stream.db")
+	db, err := gorm.Open(sqlite.Open("stream.db"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	// create the table
+	db.AutoMigrate(&Product{})
+	db.Create([]Product{
+		{Name: "apple", Price: 10, Category: "fruit"},
+		{Name: "banana", Price: 20, Category: "fruit"},
+		{Name: "carrot", Price: 30, Category: "vegetable"},
+	})
+
+	store := ghost.NewHookStore(ggorm.NewStore(Product{}, ProductQuery{}, uint64(0), db))
+	g := ghost.Ghost[Product, ProductQuery, uint64]{
+		Server: ghost.NewNegotiatingServer[Product, ProductQuery, uint64](
+			store,
+			ghost.Encodings[Product]{ghost.JSON[Product]{}, ghost.NDJSON[Product]{}, ghost.CSV[Product]{}},
+			ghost.PathIdentifier[uint64](ghost.UintPath[uint64]),
+			ghost.NewQueryParser[ProductQuery](),
+		),
+		Mux:          ghost.DefaultMux[Product, ProductQuery],
+		ErrorHandler: ghost.DefaultErrorHandler(ghost.JSON[ghost.Error]{}),
+	}
+
+	t.Run("ndjson", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/?category=fruit", nil)
+		r.Header.Set("Accept", "application/x-ndjson")
+		g.ServeHTTP(w, r)
+		if e, g := 200, w.Code; e != g {
+			t.Fatalf("expected %d, got %d, body: %s", e, g, w.Body.String())
+		}
+		names := []string{}
+		dec := json.NewDecoder(w.Body)
+		for dec.More() {
+			var p Product
+			if err := dec.Decode(&p); err != nil {
+				t.Fatalf("failed to decode json line: %v", err)
+			}
+			names = append(names, p.Name)
+		}
+		if diff := cmp.Diff([]string{"banana", "apple"}, names); diff != "" {
+			t.Errorf("unexpected products (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/?MinPrice=20", nil)
+		r.Header.Set("Accept", "text/csv")
+		g.ServeHTTP(w, r)
+		if e, g := 200, w.Code; e != g {
+			t.Fatalf("expected %d, got %d, body: %s", e, g, w.Body.String())
+		}
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		if e, g := "ID,CreatedAt,UpdatedAt,DeletedAt,Name,Price,Category", lines[0]; e != g {
+			t.Errorf("expected header %s, got %s", e, g)
+		}
+		if e, g := 3, len(lines); e != g {
+			t.Fatalf("expected %d lines, got %d", e, g)
+		}
+		if !strings.HasPrefix(lines[1], "3,") || !strings.HasSuffix(lines[1], ",carrot,30,vegetable") {
+			t.Errorf("unexpected row %s", lines[1])
+		}
+	})
+}
+
+type Author struct {
+	ID    uint64 `json:"id"`
+	Email string `json:"email" gorm:"uniqueIndex"`
+}
+
+type Book struct {
+	ID       uint64  `json:"id"`
+	Title    string  `json:"title"`
+	AuthorID uint64  `json:"author_id"`
+	Author   *Author `json:"-"`
+}
+
+func TestErrors(t *testing.T) {
+	_ = os.Remove("errors.db")
+	db, err := gorm.Open(sqlite.Open("errors.db?_foreign_keys=on"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	// create the tables
+	db.AutoMigrate(&Author{}, &Book{})
+
+	authors := ghost.New(ggorm.NewStore(Author{}, SearchQuery{}, uint64(0), db))
+	books := ghost.New(ggorm.NewStore(Book{}, SearchQuery{}, uint64(0), db))
+
+	tests := []struct {
+		name, method, path, reqBody string
+		handler                     http.Handler
+		expectedCode                int
+		expectedResBody             string
+	}{
+		{
+			name:            "GET /authors/1",
+			handler:         authors,
+			method:          "GET",
+			path:            "/1",
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		}, {
+			name:            "PUT /authors/1",
+			handler:         authors,
+			method:          "PUT",
+			path:            "/1",
+			reqBody:         `{"email":"john@example.com"}`,
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		}, {
+			name:            "DELETE /authors/1",
+			handler:         authors,
+			method:          "DELETE",
+			path:            "/1",
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		}, {
+			name:            "POST /authors",
+			handler:         authors,
+			method:          "POST",
+			path:            "/",
+			reqBody:         `{"email":"john@example.com"}`,
+			expectedCode:    201,
+			expectedResBody: `{"id":1,"email":"john@example.com"}`,
+		}, {
+			name:            "PUT /authors/1 unchanged",
+			handler:         authors,
+			method:          "PUT",
+			path:            "/1",
+			reqBody:         `{"email":"john@example.com"}`,
+			expectedCode:    200,
+			expectedResBody: `{"id":0,"email":"john@example.com"}`,
+		}, {
+			name:            "PUT /authors/1 with zero values",
+			handler:         authors,
+			method:          "PUT",
+			path:            "/1",
+			reqBody:         `{}`,
+			expectedCode:    200,
+			expectedResBody: `{"id":0,"email":""}`,
+		}, {
+			name:            "PATCH /authors/1 with zero values",
+			handler:         authors,
+			method:          "PATCH",
+			path:            "/1",
+			reqBody:         `{}`,
+			expectedCode:    200,
+			expectedResBody: `{"id":1,"email":"john@example.com"}`,
+		}, {
+			name:            "POST /authors with a duplicate email",
+			handler:         authors,
+			method:          "POST",
+			path:            "/",
+			reqBody:         `{"email":"john@example.com"}`,
+			expectedCode:    409,
+			expectedResBody: `{"error":"unique constraint violation"}`,
+		}, {
+			name:            "POST /books with a missing author",
+			handler:         books,
+			method:          "POST",
+			path:            "/",
+			reqBody:         `{"title":"Go","author_id":2}`,
+			expectedCode:    422,
+			expectedResBody: `{"error":"foreign key constraint violation"}`,
+		}, {
+			name:            "POST /books",
+			handler:         books,
+			method:          "POST",
+			path:            "/",
+			reqBody:         `{"title":"Go","author_id":1}`,
+			expectedCode:    201,
+			expectedResBody: `{"id":1,"title":"Go","author_id":1}`,
+		}, {
+			name:            "DELETE /authors/1 with a book",
+			handler:         authors,
+			method:          "DELETE",
+			path:            "/1",
+			expectedCode:    409,
+			expectedResBody: `{"error":"foreign key constraint violation"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.reqBody))
+			test.handler.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
+
+type Article struct {
+	// PUT responds with the request body, which has no id
+	ID       uint64 `json:"id,omitempty"`
+	Title    string `json:"title"`
+	Revision uint64 `json:"version" gorm:"column:version"`
+}
+
+func (a *Article) Version() string {
+	return strconv.FormatUint(a.Revision, 10)
+}
+
+func (a *Article) SetVersion(v uint64) {
+	a.Revision = v
+}
+
+func TestIfMatch(t *testing.T) {
+	_ = os.Remove("ifmatch.db")
+	db, err := gorm.Open(sqlite.Open("ifmatch.db"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	// create the tables
+	db.AutoMigrate(&Article{})
+
+	g := ghost.New(ggorm.NewStore(Article{}, SearchQuery{}, uint64(0), db))
+
+	tests := []struct {
+		name, method, path, ifMatch, reqBody string
+		expectedCode                         int
+		expectedETag                         string
+		expectedResBody                      string
+	}{
+		{
+			name:            "POST /",
+			method:          "POST",
+			path:            "/",
+			reqBody:         `{"title":"a"}`,
+			expectedCode:    201,
+			expectedResBody: `{"id":1,"title":"a","version":1}`,
+		}, {
+			name:            "PUT /1 matching",
+			method:          "PUT",
+			path:            "/1",
+			ifMatch:         `"1"`,
+			reqBody:         `{"title":"b"}`,
+			expectedCode:    200,
+			expectedETag:    `"2"`,
+			expectedResBody: `{"title":"b","version":2}`,
+		}, {
+			name:            "PUT /1 with a stale version",
+			method:          "PUT",
+			path:            "/1",
+			ifMatch:         `"1"`,
+			reqBody:         `{"title":"c"}`,
+			expectedCode:    412,
+			expectedResBody: `{"error":"Precondition Failed"}`,
+		}, {
+			name:            "PATCH /1 with a stale version",
+			method:          "PATCH",
+			path:            "/1",
+			ifMatch:         `"1"`,
+			reqBody:         `{"title":"c"}`,
+			expectedCode:    412,
+			expectedResBody: `{"error":"Precondition Failed"}`,
+		}, {
+			name:            "PATCH /1 matching",
+			method:          "PATCH",
+			path:            "/1",
+			ifMatch:         `"2"`,
+			reqBody:         `{"title":"c"}`,
+			expectedCode:    200,
+			expectedETag:    `"3"`,
+			expectedResBody: `{"id":1,"title":"c","version":3}`,
+		}, {
+			name:            "PUT /2 missing",
+			method:          "PUT",
+			path:            "/2",
+			ifMatch:         `"1"`,
+			reqBody:         `{"title":"d"}`,
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		}, {
+			name:            "DELETE /1 with a stale version",
+			method:          "DELETE",
+			path:            "/1",
+			ifMatch:         `"2"`,
+			expectedCode:    412,
+			expectedResBody: `{"error":"Precondition Failed"}`,
+		}, {
+			name:         "DELETE /1 matching",
+			method:       "DELETE",
+			path:         "/1",
+			ifMatch:      `"3"`,
+			expectedCode: 204,
+		}, {
+			name:            "DELETE /1 deleted",
+			method:          "DELETE",
+			path:            "/1",
+			ifMatch:         `"3"`,
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.reqBody))
+			if test.ifMatch != "" {
+				r.Header.Set("If-Match", test.ifMatch)
+			}
+			g.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := test.expectedETag, w.Header().Get("ETag"); e != g {
+				t.Errorf("expected ETag %s, got %s", e, g)
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
+
+type Comment struct {
+	ID     uint64 `json:"id"`
+	UserID uint64 `json:"user_id"`
+	Text   string `json:"text"`
+}
+
+func (c *Comment) ParentKey() uint64 {
+	return c.UserID
+}
+
+func (c *Comment) SetParentKey(userID uint64) {
+	c.UserID = userID
+}
+
+type CommentQuery struct {
+	Text string `where:"text"`
+}
+
+func TestNest(t *testing.T) {
+	_ = os.Remove("nest.db")
+	db, err := gorm.Open(sqlite.Open("nest.db"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	// create the tables
+	db.AutoMigrate(&User{}, &Comment{})
+	db.Create(&User{Name: "John"})
+	db.Create(&User{Name: "Bob"})
+
+	users := ghost.Nest(ggorm.NewStore(User{}, SearchQuery{}, uint64(0), db), map[string]http.Handler{
+		"comments": ghost.New(ggorm.NewStore(Comment{}, CommentQuery{}, uint64(0), db)),
+	})
+
+	tests := []struct {
+		name, method, path, reqBody string
+		expectedCode                int
+		expectedResBody             string
+	}{
+		{
+			name:            "POST /2/comments/",
+			method:          "POST",
+			path:            "/2/comments/",
+			reqBody:         `{"text":"Hi","user_id":1}`,
+			expectedCode:    201,
+			expectedResBody: `{"id":1,"user_id":2,"text":"Hi"}`,
+		}, {
+			name:            "POST /1/comments/",
+			method:          "POST",
+			path:            "/1/comments/",
+			reqBody:         `{"text":"Hello"}`,
+			expectedCode:    201,
+			expectedResBody: `{"id":2,"user_id":1,"text":"Hello"}`,
+		}, {
+			name:            "GET /1/comments/",
+			method:          "GET",
+			path:            "/1/comments/",
+			expectedCode:    200,
+			expectedResBody: `[{"id":2,"user_id":1,"text":"Hello"}]`,
+		}, {
+			name:            "GET /2/comments/",
+			method:          "GET",
+			path:            "/2/comments/",
+			expectedCode:    200,
+			expectedResBody: `[{"id":1,"user_id":2,"text":"Hi"}]`,
+		}, {
+			name:            "GET /2/comments/?limit=1",
+			method:          "GET",
+			path:            "/2/comments/?limit=1",
+			expectedCode:    200,
+			expectedResBody: `[{"id":1,"user_id":2,"text":"Hi"}]`,
+		}, {
+			name:            "GET /1/comments/1 of another user",
+			method:          "GET",
+			path:            "/1/comments/1",
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		}, {
+			name:            "PUT /1/comments/1 of another user",
+			method:          "PUT",
+			path:            "/1/comments/1",
+			reqBody:         `{"text":"Hijacked"}`,
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		}, {
+			name:            "DELETE /1/comments/1 of another user",
+			method:          "DELETE",
+			path:            "/1/comments/1",
+			expectedCode:    404,
+			expectedResBody: `{"error":"Not Found"}`,
+		}, {
+			name:            "GET /2/comments/1 untouched",
+			method:          "GET",
+			path:            "/2/comments/1",
+			expectedCode:    200,
+			expectedResBody: `{"id":1,"user_id":2,"text":"Hi"}`,
+		}, {
+			name:         "DELETE /2/comments/1",
+			method:       "DELETE",
+			path:         "/2/comments/1",
+			expectedCode: 204,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.reqBody))
+			users.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
+
+func TestIdempotencyStore(t *testing.T) {
+	_ = os.Remove("idempotency.db")
+	db, err := gorm.Open(sqlite.Open("idempotency.db"), &gorm.Config{})
+	if err != nil {
+		panic("failed to connect database")
+	}
+	// create the tables
+	db.AutoMigrate(&User{}, &ggorm.IdempotencyKey{})
+
+	g := ghost.Idempotent(ghost.New(ggorm.NewStore(User{}, SearchQuery{}, uint64(0), db)), ggorm.NewIdempotencyStore(db, 0), nil)
+	do := func(key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set(ghost.IdempotencyKeyHeader, key)
+		g.ServeHTTP(w, r)
+		return w
+	}
+
+	first := do("a", `{"Name":"John"}`)
+	if e, g := 201, first.Code; e != g {
+		t.Fatalf("expected %d, got %d", e, g)
+	}
+	replayed := do("a", `{"Name":"John"}`)
+	if e, g := 201, replayed.Code; e != g {
+		t.Errorf("expected %d, got %d", e, g)
+	}
+	if e, g := first.Body.String(), replayed.Body.String(); e != g {
+		t.Errorf("expected %s, got %s", e, g)
+	}
+	if e, g := first.Header().Get("Location"), replayed.Header().Get("Location"); e != g {
+		t.Errorf("expected %s, got %s", e, g)
+	}
+	if e, g := "true", replayed.Header().Get("Idempotent-Replayed"); e != g {
+		t.Errorf("expected %s, got %s", e, g)
+	}
+
+	reused := do("a", `{"Name":"Bob"}`)
+	if e, g := 409, reused.Code; e != g {
+		t.Errorf("expected %d, got %d", e, g)
+	}
+
+	var count int64
+	db.Model(&User{}).Count(&count)
+	if e, g := int64(1), count; e != g {
+		t.Errorf("expected %d users, got %d", e, g)
+	}
+
+	t.Run("expired", func(t *testing.T) {
+		g := ghost.Idempotent(ghost.New(ggorm.NewStore(User{}, SearchQuery{}, uint64(0), db)), ggorm.NewIdempotencyStore(db, 10*time.Millisecond), nil)
+		do := func(key, body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+			r.Header.Set(ghost.IdempotencyKeyHeader, key)
+			g.ServeHTTP(w, r)
+			return w
+		}
+		if e, g := 201, do("b", `{"Name":"John"}`).Code; e != g {
+			t.Fatalf("expected %d, got %d", e, g)
+		}
+		time.Sleep(20 * time.Millisecond)
+		// the expired key is replaced
+		w := do("b", `{"Name":"Bob"}`)
+		if e, g := 201, w.Code; e != g {
+			t.Errorf("expected %d, got %d", e, g)
+		}
+		if e, g := "", w.Header().Get("Idempotent-Replayed"); e != g {
+			t.Errorf("expected %q, got %q", e, g)
+		}
+		if !strings.Contains(w.Body.String(), `"Bob"`) {
+			t.Errorf("expected Bob, got %s", w.Body.String())
+		}
+	})
+}
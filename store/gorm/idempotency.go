@@ -0,0 +1,128 @@
+package gorm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/mash/ghost"
+	"gorm.io/gorm"
+)
+
+// IdempotencyKey is the model of the table of idempotencyStore, which has to be migrated.
+type IdempotencyKey struct {
+	Key       string `gorm:"primaryKey"`
+	Hash      string
+	Done      bool
+	Code      int
+	Header    []byte
+	Body      []byte
+	CreatedAt time.Time
+}
+
+type idempotencyStore struct {
+	// swept is when the expired keys were last deleted, in unix nanoseconds.
+	// It is the first field, so that it is aligned for the atomic operations.
+	swept int64
+	db    *gorm.DB
+	ttl   time.Duration
+}
+
+// NewIdempotencyStore returns a ghost.IdempotencyStore which stores responses in the table of IdempotencyKey.
+// Keys expire ttl after they begin, or never if ttl is 0.
+// Expired keys are deleted at most once per ttl, and an expired key which is begun again is replaced.
+// Concurrent requests with the same key are serialized by the primary key.
+func NewIdempotencyStore(db *gorm.DB, ttl time.Duration) ghost.IdempotencyStore {
+	s := &idempotencyStore{db: db, ttl: ttl}
+	atomic.StoreInt64(&s.swept, time.Now().UnixNano())
+	return s
+}
+
+func (s *idempotencyStore) Begin(ctx context.Context, key, hash string) (*ghost.IdempotentResponse, error) {
+	db := s.db.WithContext(ctx)
+	now := time.Now()
+	if err := s.sweep(db, now); err != nil {
+		return nil, err
+	}
+	err := s.create(db, key, hash)
+	var e ghost.Error
+	if err == nil || !errors.As(err, &e) || e.Code != http.StatusConflict {
+		return nil, err
+	}
+
+	// the key exists
+	var k IdempotencyKey
+	if err := db.Where(&IdempotencyKey{Key: key}).First(&k).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// aborted since
+			return nil, ghost.ErrIdempotencyKeyInFlight
+		}
+		return nil, err
+	}
+	if s.expired(k, now) {
+		result := db.Where(&IdempotencyKey{Key: k.Key, CreatedAt: k.CreatedAt}).Delete(&IdempotencyKey{})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		// another request may have replaced it meanwhile
+		if err := s.create(db, key, hash); err != nil {
+			if errors.As(err, &e) && e.Code == http.StatusConflict {
+				return nil, ghost.ErrIdempotencyKeyInFlight
+			}
+			return nil, err
+		}
+		return nil, nil
+	}
+	if k.Hash != hash {
+		return nil, ghost.ErrIdempotencyKeyReused
+	}
+	if !k.Done {
+		return nil, ghost.ErrIdempotencyKeyInFlight
+	}
+	res := ghost.IdempotentResponse{Code: k.Code, Body: k.Body}
+	if err := json.Unmarshal(k.Header, &res.Header); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (s *idempotencyStore) create(db *gorm.DB, key, hash string) error {
+	return translateError(db.Create(&IdempotencyKey{Key: key, Hash: hash}).Error, false)
+}
+
+func (s *idempotencyStore) expired(k IdempotencyKey, now time.Time) bool {
+	return s.ttl > 0 && !k.CreatedAt.After(now.Add(-s.ttl))
+}
+
+// sweep deletes the expired keys if they weren't deleted for ttl.
+// Only one of concurrent requests sweeps.
+func (s *idempotencyStore) sweep(db *gorm.DB, now time.Time) error {
+	if s.ttl <= 0 {
+		return nil
+	}
+	swept := atomic.LoadInt64(&s.swept)
+	if now.UnixNano()-swept < int64(s.ttl) || !atomic.CompareAndSwapInt64(&s.swept, swept, now.UnixNano()) {
+		return nil
+	}
+	return db.Where("created_at <= ?", now.Add(-s.ttl)).Delete(&IdempotencyKey{}).Error
+}
+
+func (s *idempotencyStore) Complete(ctx context.Context, key string, res ghost.IdempotentResponse) error {
+	header, err := json.Marshal(res.Header)
+	if err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Model(&IdempotencyKey{Key: key}).Updates(map[string]any{
+		"done":   true,
+		"code":   res.Code,
+		"header": header,
+		"body":   res.Body,
+	}).Error
+}
+
+func (s *idempotencyStore) Abort(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Delete(&IdempotencyKey{Key: key}).Error
+}
@@ -0,0 +1,229 @@
+package gorm
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/mash/ghost"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Where adds the conditions which q describes to db.
+//
+// Each non-zero field of q is a condition, configured with the where struct tag:
+//
+//	Name     string   `where:"name,like"`
+//	MinAge   int      `where:"age,gte"`
+//	Statuses []string `where:"status"`
+//	Sort     string   `schema:"sort" where:",sort"`
+//
+// The first tag option is the column, which defaults to the field name in the naming strategy of db.
+// If db has a Model, fields without a where tag which don't match a field of the model are skipped, as in the map store,
+// so that queries can carry other parameters.
+// The second is the operator, one of eq, ne, gt, gte, lt, lte, like, in and sort.
+// The operator defaults to in for slices and eq for others.
+// Whether like is case sensitive depends on the database, unlike in the map store, where it never is.
+// Zero values are skipped, except for non-nil pointers, which allows conditions on zero values.
+// Fields tagged with `where:"-"` are ignored, and embedded structs are flattened.
+// Where ignores the sort fields, which order the lists of the store, see Order.
+func Where(db *gorm.DB, q any) (*gorm.DB, error) {
+	var model *schema.Schema
+	if db.Statement.Model != nil {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(db.Statement.Model); err != nil {
+			return nil, err
+		}
+		model = stmt.Schema
+	}
+	exprs, _, err := parse(db, model, q)
+	if err != nil {
+		return nil, err
+	}
+	if len(exprs) == 0 {
+		return db, nil
+	}
+	return db.Where(clause.And(exprs...)), nil
+}
+
+// Order returns the order which the sort fields of q describe for model, see Where.
+// The value of a sort field is a comma separated list of fields of model, each prefixed with - for descending order,
+// which are matched case insensitively against their Go names, json names and columns.
+// Unknown fields are 400 Bad Request.
+func Order(db *gorm.DB, model any, q any) ([]clause.OrderByColumn, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	_, sorts, err := parse(db, stmt.Schema, q)
+	if err != nil || len(sorts) == 0 {
+		return nil, err
+	}
+	var columns []clause.OrderByColumn
+	for _, s := range sorts {
+		for _, name := range strings.Split(s, ",") {
+			name = strings.TrimSpace(name)
+			desc := strings.HasPrefix(name, "-")
+			name = strings.TrimPrefix(name, "-")
+			f := sortField(stmt.Schema, name)
+			if f == nil {
+				return nil, ghost.Error{Code: http.StatusBadRequest, Err: fmt.Errorf("unknown sort key %q", name)}
+			}
+			columns = append(columns, clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: f.DBName}, Desc: desc})
+		}
+	}
+	return columns, nil
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// sortField returns the field of s named name, or nil.
+func sortField(s *schema.Schema, name string) *schema.Field {
+	for _, f := range s.Fields {
+		if f.DBName == "" {
+			continue
+		}
+		names := []string{f.Name, f.DBName}
+		if json, _, _ := strings.Cut(f.Tag.Get("json"), ","); json != "" && json != "-" {
+			names = append(names, json)
+		}
+		for _, n := range names {
+			if normalizeName(n) == normalizeName(name) {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// parse returns the conditions and the values of the sort fields of q, on the columns of model if it isn't nil.
+func parse(db *gorm.DB, model *schema.Schema, q any) ([]clause.Expression, []string, error) {
+	v := reflect.ValueOf(q)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, nil, nil
+	}
+	return conditions(db, model, v)
+}
+
+func conditions(db *gorm.DB, model *schema.Schema, v reflect.Value) ([]clause.Expression, []string, error) {
+	var exprs []clause.Expression
+	var sorts []string
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("where")
+		if tag == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		if f.Anonymous && tag == "" {
+			for fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					break
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				e, s, err := conditions(db, model, fv)
+				if err != nil {
+					return nil, nil, err
+				}
+				exprs, sorts = append(exprs, e...), append(sorts, s...)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		} else if fv.IsZero() {
+			continue
+		}
+
+		column, op, _ := strings.Cut(tag, ",")
+		if op == "sort" {
+			if fv.Kind() != reflect.String {
+				return nil, nil, fmt.Errorf("field %s: operator sort requires a string, got %s", f.Name, fv.Type())
+			}
+			sorts = append(sorts, fv.String())
+			continue
+		}
+		if tag == "" && model != nil {
+			rf := model.LookUpField(f.Name)
+			if rf == nil || rf.DBName == "" {
+				// not a condition, the Query may carry other parameters
+				continue
+			}
+			column = rf.DBName
+		}
+		if column == "" {
+			column = db.NamingStrategy.ColumnName("", f.Name)
+		}
+		if op == "" {
+			op = "eq"
+			if fv.Kind() == reflect.Slice {
+				op = "in"
+			}
+		}
+		e, err := condition(clause.Column{Name: column}, op, fv)
+		if err != nil {
+			return nil, nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		if e != nil {
+			exprs = append(exprs, e)
+		}
+	}
+	return exprs, sorts, nil
+}
+
+func condition(column clause.Column, op string, v reflect.Value) (clause.Expression, error) {
+	if op == "in" {
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return nil, fmt.Errorf("operator in requires a slice, got %s", v.Type())
+		}
+		if v.Len() == 0 {
+			return nil, nil
+		}
+		values := make([]any, v.Len())
+		for i := range values {
+			values[i] = v.Index(i).Interface()
+		}
+		return clause.IN{Column: column, Values: values}, nil
+	}
+
+	value := v.Interface()
+	switch op {
+	case "eq":
+		return clause.Eq{Column: column, Value: value}, nil
+	case "ne":
+		return clause.Neq{Column: column, Value: value}, nil
+	case "gt":
+		return clause.Gt{Column: column, Value: value}, nil
+	case "gte":
+		return clause.Gte{Column: column, Value: value}, nil
+	case "lt":
+		return clause.Lt{Column: column, Value: value}, nil
+	case "lte":
+		return clause.Lte{Column: column, Value: value}, nil
+	case "like":
+		return clause.Like{Column: column, Value: value}, nil
+	default:
+		return nil, fmt.Errorf("unknown operator %q", op)
+	}
+}
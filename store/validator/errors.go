@@ -0,0 +1,158 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"github.com/mash/ghost"
+)
+
+// FieldError describes a field which failed validation.
+type FieldError struct {
+	// Field is the name of the field in the encoding, such as its JSON name.
+	Field string `json:"field"`
+	// JSONPath is the path of the field from the root, such as "address.lines[0]".
+	JSONPath string `json:"json_path"`
+	Tag      string `json:"tag"`
+	Param    string `json:"param,omitempty"`
+	Message  string `json:"message"`
+}
+
+// FieldErrors is the Err of the ghost.Error returned on validation failure.
+// They are in the "errors" extension, so that they are encoded, see ghost.Extender.
+type FieldErrors []FieldError
+
+func (fe FieldErrors) Extensions() map[string]any {
+	return map[string]any{"errors": fe}
+}
+
+func (fe FieldErrors) Error() string {
+	messages := make([]string, len(fe))
+	for i, e := range fe {
+		messages[i] = e.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// validationError converts the error of validating v into a ghost.Error.
+// Field names are taken from the tagKey struct tags of v, json for resources and schema for queries.
+func validationError(err error, v any, tagKey string, trans ut.Translator) ghost.Error {
+	var ves validator.ValidationErrors
+	if !errors.As(err, &ves) {
+		return ghost.Error{
+			Code: http.StatusBadRequest,
+			Err:  err,
+		}
+	}
+	t := reflect.TypeOf(v)
+	fes := make(FieldErrors, len(ves))
+	for i, ve := range ves {
+		path, field := fieldPath(t, ve.StructNamespace(), tagKey)
+		fe := FieldError{
+			Field:    field,
+			JSONPath: path,
+			Tag:      ve.Tag(),
+			Param:    ve.Param(),
+		}
+		if trans != nil {
+			// translations name the field by ve.Field, which is its Go name unless validator has a tag name func
+			fe.Message = renameField(ve.Translate(trans), ve.Field(), field)
+		} else {
+			fe.Message = fmt.Sprintf("Field validation for '%s' failed on the '%s' tag", path, ve.Tag())
+		}
+		fes[i] = fe
+	}
+	return ghost.Error{
+		Code: http.StatusBadRequest,
+		Err:  fes,
+	}
+}
+
+// fieldPath translates the struct namespace of a field, such as "User.Addresses[0].Street",
+// into its path and name in the tagKey struct tags of the root type t, such as "addresses[0].street" and "street".
+// Embedded structs without a tag name are flattened, as in encoding/json, and segments which can't be resolved are kept as is.
+func fieldPath(t reflect.Type, namespace string, tagKey string) (path, field string) {
+	// the first segment is the name of the root type
+	parts := splitNamespace(namespace)[1:]
+	segments := make([]string, 0, len(parts))
+	for i, segment := range parts {
+		name, index := segment, ""
+		if j := strings.IndexByte(segment, '['); j >= 0 {
+			name, index = segment[:j], segment[j:]
+		}
+		for t != nil && t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		field = name
+		embedded := false
+		if t != nil && t.Kind() == reflect.Struct {
+			if f, ok := t.FieldByName(name); ok {
+				n, _, _ := strings.Cut(f.Tag.Get(tagKey), ",")
+				if n != "" && n != "-" {
+					field = n
+				}
+				embedded = f.Anonymous && n == "" && index == ""
+				t = f.Type
+			} else {
+				t = nil
+			}
+		} else {
+			t = nil
+		}
+		// each index steps into an element of a slice, array or map
+		for j := strings.Count(index, "["); j > 0 && t != nil; j-- {
+			for t.Kind() == reflect.Pointer {
+				t = t.Elem()
+			}
+			switch t.Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+				t = t.Elem()
+			default:
+				t = nil
+			}
+		}
+		if embedded && i < len(parts)-1 {
+			continue
+		}
+		segments = append(segments, field+index)
+	}
+	return strings.Join(segments, "."), field
+}
+
+// renameField replaces the first occurrence of the field name old in message with new.
+func renameField(message, old, new string) string {
+	if old == new {
+		return message
+	}
+	loc := regexp.MustCompile(`\b` + regexp.QuoteMeta(old) + `\b`).FindStringIndex(message)
+	if loc == nil {
+		return message
+	}
+	return message[:loc[0]] + new + message[loc[1]:]
+}
+
+// splitNamespace splits a namespace at the dots which aren't in map keys.
+func splitNamespace(namespace string) []string {
+	var segments []string
+	depth, start := 0, 0
+	for i, c := range namespace {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '.':
+			if depth == 0 {
+				segments = append(segments, namespace[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(segments, namespace[start:])
+}
@@ -2,8 +2,8 @@ package validator
 
 import (
 	"context"
-	"net/http"
 
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 	"github.com/mash/ghost"
 )
@@ -11,18 +11,47 @@ import (
 type validatorStore[R ghost.Resource, Q ghost.Query, P ghost.PKey] struct {
 	store    ghost.Store[R, Q, P]
 	validate *validator.Validate
+	trans    ut.Translator
 }
 
-func NewStore[R ghost.Resource, Q ghost.Query, P ghost.PKey](store ghost.Store[R, Q, P], validator *validator.Validate) ghost.Store[R, Q, P] {
+// Option configures the store.
+type Option func(*options)
+
+type options struct {
+	trans ut.Translator
+}
+
+// WithTranslator localizes the messages of the FieldErrors with trans.
+// The translations must be registered to the validator, see the validator/v10/translations packages.
+func WithTranslator(trans ut.Translator) Option {
+	return func(o *options) {
+		o.trans = trans
+	}
+}
+
+// NewStore returns a store which validates resources and queries before passing them to store.
+// Validation failures are ghost.Errors with the status 400, whose Err is FieldErrors.
+// Fields are named as requests name them, by their json tags in resources and by their schema tags in queries,
+// without changing the configuration of validator.
+func NewStore[R ghost.Resource, Q ghost.Query, P ghost.PKey](store ghost.Store[R, Q, P], validator *validator.Validate, opts ...Option) ghost.Store[R, Q, P] {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
 	return validatorStore[R, Q, P]{
 		store:    store,
 		validate: validator,
+		trans:    o.trans,
 	}
 }
 
+func (s validatorStore[R, Q, P]) Unwrap() ghost.Store[R, Q, P] {
+	return s.store
+}
+
 func (s validatorStore[R, Q, P]) Create(ctx context.Context, r *R) error {
 	if err := s.validate.StructCtx(ctx, r); err != nil {
-		return validationError(err)
+		return s.resourceError(err, r)
 	}
 	return s.store.Create(ctx, r)
 }
@@ -33,25 +62,85 @@ func (s validatorStore[R, Q, P]) Read(ctx context.Context, pkey P, q *Q) (*R, er
 
 func (s validatorStore[R, Q, P]) Update(ctx context.Context, pkey P, r *R) error {
 	if err := s.validate.StructCtx(ctx, r); err != nil {
-		return validationError(err)
+		return s.resourceError(err, r)
 	}
 	return s.store.Update(ctx, pkey, r)
 }
 
+// Patch validates the patched fields only.
+func (s validatorStore[R, Q, P]) Patch(ctx context.Context, pkey P, r *R, fields []string) error {
+	if !ghost.Supports[ghost.Patcher[R, P]](s.store) {
+		return ghost.ErrNotImplemented
+	}
+	p := s.store.(ghost.Patcher[R, P])
+	if err := s.validate.StructPartialCtx(ctx, r, fields...); err != nil {
+		return s.resourceError(err, r)
+	}
+	return p.Patch(ctx, pkey, r, fields)
+}
+
+func (s validatorStore[R, Q, P]) UpdateIf(ctx context.Context, pkey P, r *R, version string) error {
+	if !ghost.Supports[ghost.ConditionalStore[R, P]](s.store) {
+		return ghost.ErrNotImplemented
+	}
+	if err := s.validate.StructCtx(ctx, r); err != nil {
+		return s.resourceError(err, r)
+	}
+	return s.store.(ghost.ConditionalStore[R, P]).UpdateIf(ctx, pkey, r, version)
+}
+
+func (s validatorStore[R, Q, P]) DeleteIf(ctx context.Context, pkey P, version string) error {
+	if !ghost.Supports[ghost.ConditionalStore[R, P]](s.store) {
+		return ghost.ErrNotImplemented
+	}
+	return s.store.(ghost.ConditionalStore[R, P]).DeleteIf(ctx, pkey, version)
+}
+
 func (s validatorStore[R, Q, P]) Delete(ctx context.Context, pkey P) error {
 	return s.store.Delete(ctx, pkey)
 }
 
 func (s validatorStore[R, Q, P]) List(ctx context.Context, q *Q) ([]R, error) {
 	if err := s.validate.StructCtx(ctx, q); err != nil {
-		return nil, validationError(err)
+		return nil, s.queryError(err, q)
 	}
 	return s.store.List(ctx, q)
 }
 
-func validationError(err error) ghost.Error {
-	return ghost.Error{
-		Code: http.StatusBadRequest,
-		Err:  err,
+func (s validatorStore[R, Q, P]) ListPage(ctx context.Context, q *Q, page ghost.Page) ([]R, string, error) {
+	if !ghost.Supports[ghost.PagedStore[R, Q]](s.store) {
+		return nil, "", ghost.ErrNotImplemented
+	}
+	if err := s.validate.StructCtx(ctx, q); err != nil {
+		return nil, "", s.queryError(err, q)
+	}
+	return s.store.(ghost.PagedStore[R, Q]).ListPage(ctx, q, page)
+}
+
+func (s validatorStore[R, Q, P]) Stream(ctx context.Context, q *Q, yield func(R) error) error {
+	if !ghost.Supports[ghost.Streamer[R, Q]](s.store) {
+		return ghost.ErrNotImplemented
+	}
+	if err := s.validate.StructCtx(ctx, q); err != nil {
+		return s.queryError(err, q)
+	}
+	return s.store.(ghost.Streamer[R, Q]).Stream(ctx, q, yield)
+}
+
+func (s validatorStore[R, Q, P]) Count(ctx context.Context, q *Q) (int, error) {
+	if !ghost.Supports[ghost.Counter[Q]](s.store) {
+		return 0, ghost.ErrNotImplemented
+	}
+	if err := s.validate.StructCtx(ctx, q); err != nil {
+		return 0, s.queryError(err, q)
 	}
+	return s.store.(ghost.Counter[Q]).Count(ctx, q)
+}
+
+func (s validatorStore[R, Q, P]) resourceError(err error, r *R) ghost.Error {
+	return validationError(err, r, "json", s.trans)
+}
+
+func (s validatorStore[R, Q, P]) queryError(err error, q *Q) ghost.Error {
+	return validationError(err, q, "schema", s.trans)
 }
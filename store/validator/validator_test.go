@@ -0,0 +1,161 @@
+package validator_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	"github.com/mash/ghost"
+	v "github.com/mash/ghost/store/validator"
+)
+
+type ValidateUser struct {
+	Name string `validate:"required"`
+}
+
+type ValidateSearchQuery struct {
+	Name string `validate:"required"`
+}
+
+func TestValidate(t *testing.T) {
+	store := ghost.NewMapStore(ValidateUser{}, ValidateSearchQuery{}, uint64(0))
+	validator := validator.New()
+	store = v.NewStore(store, validator)
+	g := ghost.New(store)
+
+	tests := []struct {
+		name, method, path, reqBody string
+		expectedCode                int
+		expectedResBody             string
+	}{
+		{
+			name:            "POST /",
+			method:          "POST",
+			path:            "/",
+			reqBody:         `{"Name":""}`,
+			expectedCode:    400,
+			expectedResBody: `{"error":"Field validation for 'Name' failed on the 'required' tag","errors":[{"field":"Name","json_path":"Name","tag":"required","message":"Field validation for 'Name' failed on the 'required' tag"}]}`,
+		}, {
+			name:            "PUT /1",
+			method:          "PUT",
+			path:            "/1",
+			reqBody:         `{"Name":""}`,
+			expectedCode:    400,
+			expectedResBody: `{"error":"Field validation for 'Name' failed on the 'required' tag","errors":[{"field":"Name","json_path":"Name","tag":"required","message":"Field validation for 'Name' failed on the 'required' tag"}]}`,
+		}, {
+			name:            "GET /",
+			method:          "GET",
+			path:            "/",
+			reqBody:         `{"Name":""}`,
+			expectedCode:    400,
+			expectedResBody: `{"error":"Field validation for 'Name' failed on the 'required' tag","errors":[{"field":"Name","json_path":"Name","tag":"required","message":"Field validation for 'Name' failed on the 'required' tag"}]}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			var body io.Reader
+			if test.method != "GET" {
+				body = strings.NewReader(test.reqBody)
+			}
+			r := httptest.NewRequest(test.method, test.path, body)
+			g.ServeHTTP(w, r)
+
+			if e, g := test.expectedCode, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
+
+type ValidateAddress struct {
+	Street string `json:"street" validate:"required"`
+}
+
+type ValidateProfile struct {
+	Email     string            `json:"email" validate:"required,email"`
+	Age       int               `json:"age" validate:"gte=18"`
+	Addresses []ValidateAddress `json:"addresses" validate:"dive"`
+}
+
+type ValidateProfileQuery struct {
+	MinAge int `schema:"min_age" validate:"gte=0"`
+}
+
+func TestValidateFieldErrors(t *testing.T) {
+	validate := validator.New()
+	english := en.New()
+	trans, _ := ut.New(english, english).GetTranslator("en")
+	if err := en_translations.RegisterDefaultTranslations(validate, trans); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, method, path, reqBody string
+		opts                        []v.Option
+		expectedResBody             string
+	}{
+		{
+			name:            "json names",
+			method:          "POST",
+			path:            "/",
+			reqBody:         `{"email":"john","age":17,"addresses":[{"street":"a"},{"street":""}]}`,
+			expectedResBody: `{"error":"Field validation for 'email' failed on the 'email' tag; Field validation for 'age' failed on the 'gte' tag; Field validation for 'addresses[1].street' failed on the 'required' tag","errors":[{"field":"email","json_path":"email","tag":"email","message":"Field validation for 'email' failed on the 'email' tag"},{"field":"age","json_path":"age","tag":"gte","param":"18","message":"Field validation for 'age' failed on the 'gte' tag"},{"field":"street","json_path":"addresses[1].street","tag":"required","message":"Field validation for 'addresses[1].street' failed on the 'required' tag"}]}`,
+		}, {
+			name:            "translated",
+			method:          "POST",
+			path:            "/",
+			reqBody:         `{"email":"john@example.com","age":17}`,
+			opts:            []v.Option{v.WithTranslator(trans)},
+			expectedResBody: `{"error":"age must be 18 or greater","errors":[{"field":"age","json_path":"age","tag":"gte","param":"18","message":"age must be 18 or greater"}]}`,
+		}, {
+			name:            "query parameter names",
+			method:          "GET",
+			path:            "/?min_age=-1",
+			expectedResBody: `{"error":"Field validation for 'min_age' failed on the 'gte' tag","errors":[{"field":"min_age","json_path":"min_age","tag":"gte","param":"0","message":"Field validation for 'min_age' failed on the 'gte' tag"}]}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := ghost.NewMapStore(ValidateProfile{}, ValidateProfileQuery{}, uint64(0))
+			g := ghost.New(v.NewStore(store, validate, test.opts...))
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.reqBody))
+			g.ServeHTTP(w, r)
+
+			if e, g := 400, w.Code; e != g {
+				t.Errorf("expected %d, got %d", e, g)
+			}
+			if e, g := test.expectedResBody, strings.TrimSpace(w.Body.String()); e != g {
+				t.Fatalf("expected %s, got %s", e, g)
+			}
+		})
+	}
+}
+
+func TestValidatorUnchanged(t *testing.T) {
+	validate := validator.New()
+	store := v.NewStore(ghost.NewMapStore(ValidateProfile{}, ValidateProfileQuery{}, uint64(0)), validate)
+	if err := store.Create(context.Background(), &ValidateProfile{Email: "john", Age: 18}); err == nil {
+		t.Fatal("expected a validation error")
+	}
+
+	var ves validator.ValidationErrors
+	if !errors.As(validate.Struct(ValidateProfile{Email: "john", Age: 18}), &ves) {
+		t.Fatal("expected ValidationErrors")
+	}
+	if e, g := "Email", ves[0].Field(); e != g {
+		t.Errorf("expected %s, got %s", e, g)
+	}
+}
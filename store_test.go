@@ -0,0 +1,87 @@
+package ghost_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mash/ghost"
+)
+
+// TestMapStoreConcurrency hammers the map stores from many goroutines.
+// Run it with -race to detect data races.
+func TestMapStoreConcurrency(t *testing.T) {
+	t.Run("uint64", func(t *testing.T) {
+		store := ghost.NewMapStore(Item{}, SearchQuery{}, uint64(0))
+		testConcurrency(t, ghost.New(store))
+	})
+	t.Run("string", func(t *testing.T) {
+		store := ghost.NewMapStrStore(StrItem{}, SearchQuery{}, string(""))
+		testConcurrency(t, ghost.NewS(store))
+	})
+}
+
+func testConcurrency(t *testing.T, h http.Handler) {
+	const goroutines = 16
+	const iterations = 50
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		var r io.Reader
+		if body != "" {
+			r = strings.NewReader(body)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, r))
+		return w
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines*iterations)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				w := do("POST", "/", fmt.Sprintf(`{"name":"%d-%d"}`, g, i))
+				if w.Code != 201 {
+					errs <- fmt.Errorf("POST: expected 201, got %d", w.Code)
+					continue
+				}
+				location := w.Header().Get("Location")
+
+				for _, test := range []struct {
+					method, path, body string
+					expectedCode       int
+				}{
+					{"GET", location, "", 200},
+					{"PUT", location, `{"name":"updated"}`, 200},
+					{"GET", "/", "", 200},
+				} {
+					if w := do(test.method, test.path, test.body); w.Code != test.expectedCode {
+						errs <- fmt.Errorf("%s %s: expected %d, got %d", test.method, test.path, test.expectedCode, w.Code)
+					}
+				}
+				// keep every other resource
+				if i%2 == 0 {
+					if w := do("DELETE", location, ""); w.Code != 204 {
+						errs <- fmt.Errorf("DELETE %s: expected 204, got %d", location, w.Code)
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	w := do("GET", "/", "")
+	if e, g := goroutines*iterations/2, strings.Count(w.Body.String(), `"name":"updated"`); e != g {
+		t.Errorf("expected %d resources, got %d", e, g)
+	}
+}
@@ -0,0 +1,365 @@
+package ghost
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// filter returns the resources in rs which match the conditions of q, sorted by the sort field of q.
+//
+// Each non-zero field of q is a condition on the field of R with the same name, configured with the where struct tag
+// the same way as in the gorm store:
+//
+//	Name     string   `where:"name,like"`
+//	MinAge   int      `where:"age,gte"`
+//	Statuses []string `where:"status"`
+//	Sort     string   `schema:"sort" where:",sort"`
+//
+// The first tag option names the field of R, matched case insensitively against its Go name, json name or snake case name.
+// The second is the operator, one of eq, ne, gt, gte, lt, lte, like, in and sort.
+// The operator defaults to in for slices and eq for others. like is case insensitive.
+// The value of a sort field is a comma separated list of field names of R, each prefixed with - for descending order.
+// Zero values are skipped, except for non-nil pointers, which allows conditions on zero values.
+// Fields tagged with `where:"-"` are ignored.
+func filter[R Resource, Q Query](rs []R, q *Q) ([]R, error) {
+	if q == nil {
+		return rs, nil
+	}
+	qv := reflect.ValueOf(q).Elem()
+	if qv.Kind() != reflect.Struct {
+		return rs, nil
+	}
+	var r R
+	fields := map[string][]int{}
+	resourceFields(reflect.TypeOf(r), nil, fields)
+
+	conds, sorts, err := conditions(qv, fields)
+	if err != nil {
+		return nil, err
+	}
+	if len(conds) > 0 {
+		var matched []R
+		for _, r := range rs {
+			if matchAll(reflect.ValueOf(r), conds) {
+				matched = append(matched, r)
+			}
+		}
+		rs = matched
+	}
+	if len(sorts) > 0 {
+		sort.SliceStable(rs, func(i, j int) bool {
+			vi, vj := reflect.ValueOf(rs[i]), reflect.ValueOf(rs[j])
+			for _, s := range sorts {
+				c, _ := compare(fieldOf(vi, s.index), fieldOf(vj, s.index))
+				if c != 0 {
+					return (c < 0) != s.desc
+				}
+			}
+			return false
+		})
+	}
+	return rs, nil
+}
+
+type condition struct {
+	index []int
+	op    string
+	value reflect.Value
+}
+
+type sortKey struct {
+	index []int
+	desc  bool
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// resourceFields maps the normalized names of the fields of t to their indexes, flattening embedded structs.
+func resourceFields(t reflect.Type, index []int, fields map[string][]int) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fi := append(append([]int{}, index...), i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			resourceFields(f.Type, fi, fields)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		names := []string{f.Name}
+		if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+			names = append(names, name)
+		}
+		for _, name := range names {
+			if _, ok := fields[normalizeName(name)]; !ok {
+				fields[normalizeName(name)] = fi
+			}
+		}
+	}
+}
+
+func conditions(v reflect.Value, fields map[string][]int) ([]condition, []sortKey, error) {
+	var conds []condition
+	var sorts []sortKey
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("where")
+		if tag == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		if f.Anonymous && tag == "" && fv.Kind() == reflect.Struct {
+			c, s, err := conditions(fv, fields)
+			if err != nil {
+				return nil, nil, err
+			}
+			conds, sorts = append(conds, c...), append(sorts, s...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		} else if fv.IsZero() {
+			continue
+		}
+
+		name, op, _ := strings.Cut(tag, ",")
+		if op == "sort" {
+			s, err := sortKeys(fv.String(), fields)
+			if err != nil {
+				return nil, nil, err
+			}
+			sorts = append(sorts, s...)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		index, ok := fields[normalizeName(name)]
+		if !ok {
+			if tag == "" {
+				// not a condition, the Query may carry other parameters
+				continue
+			}
+			return nil, nil, fmt.Errorf("field %s: no field %q in the resource", f.Name, name)
+		}
+		if op == "" {
+			op = "eq"
+			if fv.Kind() == reflect.Slice {
+				op = "in"
+			}
+		}
+		switch op {
+		case "eq", "ne", "gt", "gte", "lt", "lte":
+		case "like":
+			if fv.Kind() != reflect.String {
+				return nil, nil, fmt.Errorf("field %s: operator like requires a string, got %s", f.Name, fv.Type())
+			}
+			fv = reflect.ValueOf(likePattern(fv.String()))
+		case "in":
+			if fv.Kind() != reflect.Slice && fv.Kind() != reflect.Array {
+				return nil, nil, fmt.Errorf("field %s: operator in requires a slice, got %s", f.Name, fv.Type())
+			}
+			if fv.Len() == 0 {
+				continue
+			}
+		default:
+			return nil, nil, fmt.Errorf("field %s: unknown operator %q", f.Name, op)
+		}
+		conds = append(conds, condition{index: index, op: op, value: fv})
+	}
+	return conds, sorts, nil
+}
+
+func sortKeys(s string, fields map[string][]int) ([]sortKey, error) {
+	var keys []sortKey
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		desc := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+		index, ok := fields[normalizeName(name)]
+		if !ok {
+			return nil, Error{Code: http.StatusBadRequest, Err: fmt.Errorf("unknown sort key %q", name)}
+		}
+		keys = append(keys, sortKey{index: index, desc: desc})
+	}
+	return keys, nil
+}
+
+// likePattern compiles a SQL LIKE pattern, where % matches any sequence of characters and _ matches any character.
+// It is case insensitive, like LIKE in SQLite and MySQL.
+func likePattern(p string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	for _, c := range p {
+		switch c {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// fieldOf returns the field of v at index, or an invalid Value if it is behind a nil pointer.
+func fieldOf(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func matchAll(r reflect.Value, conds []condition) bool {
+	for _, c := range conds {
+		if !c.match(fieldOf(r, c.index)) {
+			return false
+		}
+	}
+	return true
+}
+
+// match reports whether v satisfies the condition. Like NULL in SQL, an invalid v satisfies no condition.
+func (c condition) match(v reflect.Value) bool {
+	if !v.IsValid() {
+		return false
+	}
+	switch c.op {
+	case "like":
+		return v.Kind() == reflect.String && c.value.Interface().(*regexp.Regexp).MatchString(v.String())
+	case "in":
+		for i := 0; i < c.value.Len(); i++ {
+			if equal(v, c.value.Index(i)) {
+				return true
+			}
+		}
+		return false
+	case "eq":
+		return equal(v, c.value)
+	case "ne":
+		return !equal(v, c.value)
+	}
+	cmp, ok := compare(v, c.value)
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case "gt":
+		return cmp > 0
+	case "gte":
+		return cmp >= 0
+	case "lt":
+		return cmp < 0
+	default:
+		return cmp <= 0
+	}
+}
+
+func equal(a, b reflect.Value) bool {
+	if c, ok := compare(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// compare compares numbers, strings, bools and times, possibly of different types.
+// ok is false if a and b are not comparable.
+func compare(a, b reflect.Value) (c int, ok bool) {
+	if !a.IsValid() || !b.IsValid() {
+		return 0, false
+	}
+	for a.Kind() == reflect.Pointer && !a.IsNil() {
+		a = a.Elem()
+	}
+	for b.Kind() == reflect.Pointer && !b.IsNil() {
+		b = b.Elem()
+	}
+	switch {
+	case a.Type() == timeType && b.Type() == timeType:
+		ta, tb := a.Interface().(time.Time), b.Interface().(time.Time)
+		switch {
+		case ta.Before(tb):
+			return -1, true
+		case ta.After(tb):
+			return 1, true
+		}
+		return 0, true
+	case isNumber(a) && isNumber(b):
+		fa, fb := toFloat(a), toFloat(b)
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	case a.Kind() == reflect.String && b.Kind() == reflect.String:
+		return strings.Compare(a.String(), b.String()), true
+	case a.Kind() == reflect.Bool && b.Kind() == reflect.Bool:
+		switch {
+		case a.Bool() == b.Bool():
+			return 0, true
+		case b.Bool():
+			return -1, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+func isNumber(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint())
+	}
+	return v.Float()
+}